@@ -1,21 +1,35 @@
 package sittella
 
 import (
+	stdcontext "context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/http"
-	"sync"
+	"time"
 
 	"github.com/dimmerz92/sittella/core"
 	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/database/migrations"
 	"github.com/dimmerz92/sittella/mailer"
 	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/utils"
 )
 
 type Config struct {
 	DB           *database.Database
 	SessionStore sessions.Store
 	Mailer       mailer.Mailer
+
+	// Logger specifies the logger used by the app and request contexts.
+	// Defaults to slog.Default() if not set.
+	Logger *slog.Logger
+
+	// Migrations specifies the migration files applied to DB when the app starts.
+	// See the migrations package for the file naming scheme. No migrations are
+	// applied if not set.
+	Migrations fs.FS
 }
 
 type app struct {
@@ -28,8 +42,13 @@ type app struct {
 	db           *database.Database
 	sessionStore sessions.Store
 	mailer       mailer.Mailer
+	logger       *slog.Logger
+	migrations   fs.FS
 }
 
+// contextKey is the request context key the request scoped context is stored under.
+type contextKey struct{}
+
 func New(config Config) core.App {
 	if config.DB == nil {
 		panic("App requires non-nil database")
@@ -47,6 +66,8 @@ func New(config Config) core.App {
 		db:           config.DB,
 		sessionStore: config.SessionStore,
 		mailer:       config.Mailer,
+		logger:       utils.Coalesce(config.Logger, slog.Default()),
+		migrations:   config.Migrations,
 	}
 }
 
@@ -67,6 +88,8 @@ func (a *app) OnHandlerError(callback func(c core.Context, err error)) {
 
 func (a *app) serveMux() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := a.newContext(w, r)
+
 		handler := func(c core.Context) error {
 			a.mux.ServeHTTP(c.Response(), c.Request())
 			return nil
@@ -76,17 +99,48 @@ func (a *app) serveMux() http.Handler {
 			handler = a.middleware[i](handler)
 		}
 
-		if err := handler(&context{res: w, req: r}); err != nil {
+		if err := handler(ctx); err != nil {
 			panic(err)
 		}
+
+		if !ctx.res.Written() {
+			ctx.res.WriteHeader(http.StatusOK)
+		}
 	})
 }
 
-// Start runs the http server on the given port number.
+// newContext returns a new request scoped context attached to the request.
+// The session is loaded from the store on first access and saved immediately
+// before the response headers are sent.
+func (a *app) newContext(w http.ResponseWriter, r *http.Request) *context {
+	ctx := &context{
+		res:          &responseWriter{ResponseWriter: w},
+		db:           a.db,
+		mailer:       a.mailer,
+		sessionStore: a.sessionStore,
+		logger:       a.logger,
+		started:      time.Now(),
+	}
+	ctx.req = r.WithContext(stdcontext.WithValue(r.Context(), contextKey{}, ctx))
+	ctx.res.Before(ctx.saveSession)
+	return ctx
+}
+
+// Start applies any configured migrations, then runs the http server on the
+// given port number.
 func (a *app) Start(port int) error {
 	if port < 1 || port > 65535 {
 		return errors.New("port must be between 1 and 65535")
 	}
+	if a.migrations != nil {
+		migrator, err := migrations.New(a.db, a.migrations)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Up(stdcontext.Background()); err != nil {
+			return err
+		}
+	}
 	if a.onStart != nil {
 		a.onStart()
 	}
@@ -96,7 +150,7 @@ func (a *app) Start(port int) error {
 		Addr:    fmt.Sprintf(":%d", port),
 	}
 
-	fmt.Printf("listening on port %d", port)
+	a.logger.Info("listening", "port", port)
 	return a.server.ListenAndServe()
 }
 
@@ -113,26 +167,38 @@ func (a *app) serve(method, path string, handler core.HandlerFunc, middleware ..
 	}
 
 	a.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != method || method != "any" {
+		if method != "any" && r.Method != method {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		ctx := &context{
-			req:     r,
-			res:     w,
-			store:   sync.Map{},
-			db:      a.db,
-			mailer:  a.mailer,
-			session: a.sessionStore.Session(w, r),
+		ctx, ok := r.Context().Value(contextKey{}).(*context)
+		if !ok {
+			ctx = a.newContext(w, r)
 		}
+		ctx.req = r
 
-		if err := handler(ctx); err != nil {
+		err := handler(ctx)
+		if err == nil && !ctx.res.Written() {
+			// save before the fallback headers are sent so a failed save still
+			// reaches the error handler.
+			ctx.saveSession()
+		}
+		if err == nil && ctx.sessionErr != nil {
+			err = ctx.sessionErr
+		}
+		ctx.handlerErr = err
+
+		if err != nil {
 			if a.errorHandler != nil {
 				a.errorHandler(ctx, err)
-				return
+			} else if !ctx.res.Written() {
+				http.Error(ctx.res, "internal server error", http.StatusInternalServerError)
 			}
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+
+		if !ctx.res.Written() {
+			ctx.res.WriteHeader(http.StatusOK)
 		}
 	})
 }
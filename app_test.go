@@ -0,0 +1,117 @@
+package sittella
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+	"github.com/dimmerz92/sittella/mailer"
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/memorystore"
+)
+
+// newTestApp returns an app on a temporary database with an in memory session
+// store, or config.SessionStore if it is set, logging to w.
+func newTestApp(t *testing.T, config Config, w io.Writer) *app {
+	t.Helper()
+
+	if config.DB == nil {
+		config.DB = sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3") + "?_pragma=busy_timeout(5000)")
+		t.Cleanup(func() { config.DB.Close() })
+	}
+	if config.SessionStore == nil {
+		store := memorystore.New(time.Minute, time.Hour, sessions.CookieOpts{Name: "session"})
+		t.Cleanup(store.Stop)
+		config.SessionStore = store
+	}
+	if config.Mailer == nil {
+		config.Mailer = &mailer.DefaultMailer{}
+	}
+	if w == nil {
+		w = io.Discard
+	}
+	config.Logger = slog.New(slog.NewJSONHandler(w, nil))
+
+	return New(config).(*app)
+}
+
+// do serves r with the app and returns the recorded response.
+func do(a *app, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	a.serveMux().ServeHTTP(w, r)
+	return w
+}
+
+func TestStartMigrations(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	a := newTestApp(t, Config{Migrations: fstest.MapFS{
+		"0001_create_items.up.sql": {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY)")},
+	}}, nil)
+
+	started := make(chan error, 1)
+	a.OnStart(func() {
+		_, err := a.db.Exec("INSERT INTO items (id) VALUES (1)")
+		started <- err
+	})
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- a.Start(port) }()
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("expected migrations to be applied before OnStart: %v", err)
+		}
+	case err := <-stopped:
+		t.Fatalf("failed to start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for OnStart")
+	}
+
+	// wait for the server to accept connections before stopping it.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d/", port))
+		if err == nil {
+			res.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := a.Stop(); err != nil {
+		t.Fatalf("failed to stop: %v", err)
+	}
+	if err := <-stopped; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("expected %v got %v", http.ErrServerClosed, err)
+	}
+
+	// failed migrations prevent the app from starting.
+	a = newTestApp(t, Config{Migrations: fstest.MapFS{
+		"0001_invalid.up.sql": {Data: []byte("CREATE TABLE")},
+	}}, nil)
+
+	var ran bool
+	a.OnStart(func() { ran = true })
+
+	if err := a.Start(port); err == nil || ran {
+		t.Fatalf("expected migration error before OnStart, got %v (OnStart ran %v)", err, ran)
+	}
+}
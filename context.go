@@ -2,29 +2,50 @@ package sittella
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/a-h/templ"
+	"github.com/dimmerz92/sittella/core"
 	"github.com/dimmerz92/sittella/database"
 	"github.com/dimmerz92/sittella/mailer"
 	"github.com/dimmerz92/sittella/sessions"
 )
 
 type context struct {
-	req     *http.Request
-	res     http.ResponseWriter
-	store   sync.Map
-	db      *database.Database
-	mailer  mailer.Mailer
-	session sessions.Session
+	req    *http.Request
+	res    *responseWriter
+	store  sync.Map
+	db     *database.Database
+	mailer mailer.Mailer
+	logger *slog.Logger
+
+	// started specifies the datetime the request started.
+	started time.Time
+
+	sessionStore sessions.Store
+	sessionOnce  sync.Once
+	session      sessions.Session
+
+	// sessionSaved is true once the session has been automatically saved.
+	sessionSaved bool
+
+	// sessionErr holds the error from automatically saving the session.
+	sessionErr error
+
+	// handlerErr holds the error returned by the route handler, including one
+	// passed to the error handler.
+	handlerErr error
 }
 
 // Request returns the underlying request.
 func (c *context) Request() *http.Request { return c.req }
 
-// Response returns the underlying response writer.
-func (c *context) Response() http.ResponseWriter { return c.res }
+// Response returns the response writer for the request.
+func (c *context) Response() core.ResponseWriter { return c.res }
 
 // Set adds the key value pair to the context store.
 func (c *context) Set(key string, value any) { c.store.Store(key, value) }
@@ -36,11 +57,88 @@ func (c *context) Get(key string) (any, bool) { return c.store.Load(key) }
 // DB returns the underlying database.
 func (c *context) DB() *database.Database { return c.db }
 
+// Tx returns the request transaction opened by the Transaction or
+// ReadOnlyTransaction middleware, or nil if there is none.
+func (c *context) Tx() *database.Tx {
+	tx, _ := Value(c, txKey)
+	return tx
+}
+
 // Mailer returns the underlying mailer.
 func (c *context) Mailer() mailer.Mailer { return c.mailer }
 
 // Session returns the unique user session.
-func (c *context) Session() sessions.Session { return c.session }
+// The session is loaded from the session store on first access.
+func (c *context) Session() sessions.Session {
+	c.sessionOnce.Do(func() {
+		c.session = c.sessionStore.Session(c.res, c.req)
+	})
+	return c.session
+}
+
+// saveSession persists the session if it has been loaded, unless the route opted
+// out with ReadOnlySession. If the store implements sessions.Detector and the
+// request carries a session cookie, the session is loaded so that flash messages
+// added before the request started are removed, so they only survive until the
+// next request whether or not it uses the session. It is registered to run immediately before the response headers are sent, and
+// is called when the handler returns without sending them. Only the first call
+// has any effect, and calls while the response is held are deferred until it is
+// sent.
+func (c *context) saveSession() {
+	if c.sessionSaved || c.res.held {
+		return
+	}
+	c.sessionSaved = true
+
+	if readOnly, _ := Value(c, readOnlySessionKey); readOnly {
+		return
+	}
+
+	if c.session == nil {
+		detector, ok := c.sessionStore.(sessions.Detector)
+		if !ok || !detector.HasSession(c.req) {
+			return
+		}
+		c.Session()
+	}
+
+	if err := sessions.ExpireFlashes(c.session, c.started); err != nil {
+		c.Logger().Error("failed to expire flash messages", "error", err)
+	}
+
+	if err := c.session.Save(); err != nil {
+		c.sessionErr = fmt.Errorf("sittella: save session: %w", err)
+		c.Logger().Error("failed to save session", "error", err)
+	}
+}
+
+// Flash adds a one-time message of the given kind to the session.
+// The message is kept until it is read with Flashes or the next request ends,
+// such as after a redirect.
+func (c *context) Flash(kind, message string) error {
+	return sessions.AddFlash(c.Session(), kind, message)
+}
+
+// Flashes returns the pending flash messages and removes them from the session.
+func (c *context) Flashes() ([]sessions.Flash, error) {
+	return sessions.Flashes(c.Session())
+}
+
+// Logger returns the app logger populated with the request ID, route pattern
+// and user ID where they are known.
+func (c *context) Logger() *slog.Logger {
+	logger := c.logger
+	if id, ok := Value(c, RequestIDKey); ok {
+		logger = logger.With("request_id", id)
+	}
+	if c.req.Pattern != "" {
+		logger = logger.With("route", c.req.Pattern)
+	}
+	if id, ok := Value(c, UserIDKey); ok {
+		logger = logger.With("user_id", id)
+	}
+	return logger
+}
 
 // HTML writes the given status and html string to the response.
 func (c *context) HTML(status int, html string) error {
@@ -12,9 +12,11 @@ type App interface {
 	OnStop(callback func())
 
 	// OnHandlerError runs the given callback as a central error handler.
+	// Errors from automatically saving the session are also passed to the callback.
 	OnHandlerError(callback func(c Context, err error))
 
-	// Start runs the http server on the given port number.
+	// Start applies any configured migrations, then runs the http server on the
+	// given port number.
 	Start(port int) error
 
 	// Stop sends a stop signal to the http server.
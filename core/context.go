@@ -1,6 +1,7 @@
 package core
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/a-h/templ"
@@ -14,8 +15,8 @@ type Context interface {
 	// Request returns the underlying request.
 	Request() *http.Request
 
-	// Response returns the underlying response writer.
-	Response() http.ResponseWriter
+	// Response returns the response writer for the request.
+	Response() ResponseWriter
 
 	// Set adds the key value pair to the context store.
 	Set(key string, value any)
@@ -27,12 +28,29 @@ type Context interface {
 	// DB returns the underlying database.
 	DB() *database.Database
 
+	// Tx returns the request transaction opened by the Transaction or
+	// ReadOnlyTransaction middleware, or nil if there is none.
+	Tx() *database.Tx
+
 	// Mailer returns the underlying mailer.
 	Mailer() mailer.Mailer
 
 	// Session returns the unique user session.
+	// The session is loaded from the session store on first access.
 	Session() sessions.Session
 
+	// Flash adds a one-time message of the given kind to the session.
+	// The message is kept until it is read with Flashes or the next request using
+	// the session ends, such as after a redirect.
+	Flash(kind, message string) error
+
+	// Flashes returns the pending flash messages and removes them from the session.
+	Flashes() ([]sessions.Flash, error)
+
+	// Logger returns the app logger populated with the request ID, route pattern
+	// and user ID where they are known.
+	Logger() *slog.Logger
+
 	// HTML writes the given status and html string to the response.
 	HTML(status int, html string) error
 
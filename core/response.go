@@ -0,0 +1,28 @@
+package core
+
+import "net/http"
+
+// ResponseWriter defines a http.ResponseWriter that tracks the state of the
+// response and allows callbacks to run immediately before headers are sent.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+
+	// Status returns the response status, or 200 - OK if none has been written.
+	Status() int
+
+	// Written returns true if the response headers have been sent.
+	Written() bool
+
+	// Size returns the number of body bytes written to the response.
+	Size() int
+
+	// Before registers a callback to run immediately before the response
+	// headers are sent. Callbacks run in the order they were registered, and Status
+	// returns the status being sent while they run.
+	Before(callback func())
+
+	// Unwrap returns the underlying response writer for use by http.ResponseController.
+	Unwrap() http.ResponseWriter
+}
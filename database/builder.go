@@ -0,0 +1,589 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvalidOrder = errors.New("database: invalid order")
+
+var ErrUnbounded = errors.New("database: statement has no conditions")
+
+// condition defines an SQL fragment with ? placeholders and its arguments.
+type condition struct {
+	sql  string
+	args []any
+}
+
+// newCondition returns a condition for query and args. If args is a single map
+// or struct, query uses :name parameters that are bound from it. Slice arguments
+// are expanded for IN (?) clauses.
+func newCondition(query string, args []any) (condition, error) {
+	if len(args) == 1 && named(args[0]) {
+		var err error
+		query, args, err = sqlx.Named(query, args[0])
+		if err != nil {
+			return condition{}, err
+		}
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return condition{}, err
+	}
+
+	return condition{sql: query, args: args}, nil
+}
+
+// named returns true if arg holds named parameters: a map with string keys or
+// a struct that is not scanned as a single value.
+func named(arg any) bool {
+	if _, ok := arg.(driver.Valuer); ok {
+		return false
+	}
+
+	t := reflect.TypeOf(arg)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	case reflect.Struct:
+		return !scannable(t)
+	default:
+		return false
+	}
+}
+
+// conditions holds the WHERE conditions of a statement, which are joined with AND.
+type conditions struct {
+	list []condition
+	err  error
+}
+
+// add adds a condition, recording the first error.
+func (c *conditions) add(query string, args []any) {
+	cond, err := newCondition(query, args)
+	if err != nil {
+		c.err = firstErr(c.err, err)
+		return
+	}
+	c.list = append(c.list, cond)
+}
+
+// write writes the WHERE clause, if there are any conditions.
+func (c *conditions) write(sb *strings.Builder, args *[]any) {
+	for i, cond := range c.list {
+		if i == 0 {
+			sb.WriteString(" WHERE ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("(" + cond.sql + ")")
+		*args = append(*args, cond.args...)
+	}
+}
+
+// firstErr returns err if it is set, and next otherwise, so that builders
+// report the first error.
+func firstErr(err, next error) error {
+	if err != nil {
+		return err
+	}
+	return next
+}
+
+// bindType returns the sqlx bind type for the placeholders of q, using the type
+// of a Database or Tx and the driver name otherwise.
+func bindType(q Querier) int {
+	switch q := q.(type) {
+	case *Database:
+		return dbtypes[q.dbtype]
+	case *Tx:
+		return dbtypes[q.dbtype]
+	default:
+		return sqlx.BindType(q.DriverName())
+	}
+}
+
+// writer returns the querier for statements that write, which is the writer
+// pool if q is a Database without a transaction in ctx.
+func writer(ctx context.Context, q Querier) Querier {
+	q = querier(ctx, q)
+	if d, ok := q.(*Database); ok {
+		return d.Writer()
+	}
+	return q
+}
+
+// returning writes a RETURNING clause, if there are any columns.
+func returning(sb *strings.Builder, columns []string) {
+	if len(columns) > 0 {
+		sb.WriteString(" RETURNING " + strings.Join(columns, ", "))
+	}
+}
+
+// SelectBuilder builds a SELECT statement. Its methods modify and return the
+// builder so that calls may be chained, or applied conditionally:
+//
+//	b := database.Select("id", "name").From("users").Where("active = ?", true)
+//	if search != "" {
+//		b.Where("name LIKE ?", "%"+search+"%")
+//	}
+//	b.OrderBy(sort, "name", "created_at").Limit(20)
+type SelectBuilder struct {
+	columns []string
+	from    string
+	joins   []condition
+	where   conditions
+	orderBy []string
+	limit   int
+	offset  int
+	err     error
+}
+
+// Select returns a builder for a SELECT statement of columns.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+// From sets the table to select from.
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Join adds a join clause, such as "JOIN posts ON posts.user_id = users.id",
+// with the given arguments.
+func (b *SelectBuilder) Join(join string, args ...any) *SelectBuilder {
+	cond, err := newCondition(join, args)
+	if err != nil {
+		b.err = firstErr(b.err, err)
+		return b
+	}
+	b.joins = append(b.joins, cond)
+	return b
+}
+
+// Where adds a condition, which is joined to any others with AND. Arguments
+// bind to ? placeholders, with slices expanded for IN (?), or a single map or
+// struct argument binds to :name parameters.
+func (b *SelectBuilder) Where(cond string, args ...any) *SelectBuilder {
+	b.where.add(cond, args)
+	return b
+}
+
+// WhereIf adds a condition as Where does if ok is true.
+func (b *SelectBuilder) WhereIf(ok bool, cond string, args ...any) *SelectBuilder {
+	if ok {
+		b.where.add(cond, args)
+	}
+	return b
+}
+
+// OrderBy adds the comma separated columns in sort to the ORDER BY clause, with
+// a column prefixed by "-" sorted in descending order (e.g. "-created_at,name").
+// Each column must be one of allowed, so sort may come from user input; Build
+// returns ErrInvalidOrder otherwise. An empty sort is ignored.
+func (b *SelectBuilder) OrderBy(sort string, allowed ...string) *SelectBuilder {
+	if sort == "" {
+		return b
+	}
+
+	for column := range strings.SplitSeq(sort, ",") {
+		column = strings.TrimSpace(column)
+
+		direction := "ASC"
+		if c, ok := strings.CutPrefix(column, "-"); ok {
+			column, direction = c, "DESC"
+		}
+
+		if !slices.Contains(allowed, column) {
+			b.err = firstErr(b.err, fmt.Errorf("%w: %q", ErrInvalidOrder, column))
+			return b
+		}
+
+		b.orderBy = append(b.orderBy, column+" "+direction)
+	}
+
+	return b
+}
+
+// Limit sets the maximum number of rows returned. A limit of zero or less
+// returns all rows.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset sets the number of rows skipped.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Build returns the statement and its arguments, with placeholders for the
+// database type of q.
+func (b *SelectBuilder) Build(q Querier) (string, []any, error) {
+	if err := firstErr(b.err, b.where.err); err != nil {
+		return "", nil, err
+	}
+	if len(b.columns) == 0 {
+		return "", nil, errors.New("database: select requires columns")
+	}
+
+	var sb strings.Builder
+	var args []any
+
+	sb.WriteString("SELECT " + strings.Join(b.columns, ", "))
+	if b.from != "" {
+		sb.WriteString(" FROM " + b.from)
+	}
+	for _, join := range b.joins {
+		sb.WriteString(" " + join.sql)
+		args = append(args, join.args...)
+	}
+	b.where.write(&sb, &args)
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+	switch {
+	case b.limit > 0:
+		sb.WriteString(" LIMIT " + strconv.Itoa(b.limit))
+	case b.offset > 0:
+		// SQLite only accepts OFFSET after a LIMIT, where -1 is no limit.
+		sb.WriteString(" LIMIT -1")
+	}
+	if b.offset > 0 {
+		sb.WriteString(" OFFSET " + strconv.Itoa(b.offset))
+	}
+
+	return sqlx.Rebind(bindType(q), sb.String()), args, nil
+}
+
+// Get scans the single row returned by the statement into dest, returning
+// ErrNotFound if there is none.
+func (b *SelectBuilder) Get(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return get(ctx, querier(ctx, q), dest, query, args)
+}
+
+// Select scans all rows returned by the statement into dest, a pointer to a slice.
+func (b *SelectBuilder) Select(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, querier(ctx, q), dest, query, args...)
+}
+
+// InsertBuilder builds an INSERT statement.
+type InsertBuilder struct {
+	table     string
+	columns   []string
+	rows      [][]any
+	returning []string
+	err       error
+}
+
+// Insert returns a builder for an INSERT statement into table.
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+// Columns sets the columns to insert.
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = columns
+	return b
+}
+
+// Values adds a row of values, one for each column.
+func (b *InsertBuilder) Values(values ...any) *InsertBuilder {
+	if len(values) != len(b.columns) {
+		b.err = firstErr(b.err, fmt.Errorf("database: insert has %d columns but %d values", len(b.columns), len(values)))
+		return b
+	}
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// Returning sets the columns returned by the statement, which are scanned with
+// Get or Select.
+func (b *InsertBuilder) Returning(columns ...string) *InsertBuilder {
+	b.returning = columns
+	return b
+}
+
+// Build returns the statement and its arguments, with placeholders for the
+// database type of q.
+func (b *InsertBuilder) Build(q Querier) (string, []any, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.columns) == 0 || len(b.rows) == 0 {
+		return "", nil, errors.New("database: insert requires columns and values")
+	}
+
+	var sb strings.Builder
+	var args []any
+
+	sb.WriteString("INSERT INTO " + b.table + " (" + strings.Join(b.columns, ", ") + ") VALUES ")
+
+	placeholders := "(" + strings.Repeat("?, ", len(b.columns)-1) + "?)"
+	for i, row := range b.rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholders)
+		args = append(args, row...)
+	}
+	returning(&sb, b.returning)
+
+	return sqlx.Rebind(bindType(q), sb.String()), args, nil
+}
+
+// Exec executes the statement and returns the number of rows affected.
+func (b *InsertBuilder) Exec(ctx context.Context, q Querier) (int64, error) {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return 0, err
+	}
+	return Exec(ctx, q, query, args...)
+}
+
+// Get scans the single row returned by the statement into dest.
+func (b *InsertBuilder) Get(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return get(ctx, writer(ctx, q), dest, query, args)
+}
+
+// Select scans all rows returned by the statement into dest, a pointer to a slice.
+func (b *InsertBuilder) Select(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, writer(ctx, q), dest, query, args...)
+}
+
+// UpdateBuilder builds an UPDATE statement.
+type UpdateBuilder struct {
+	table     string
+	set       []condition
+	where     conditions
+	all       bool
+	returning []string
+}
+
+// Update returns a builder for an UPDATE statement of table.
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+// Set sets column to value.
+func (b *UpdateBuilder) Set(column string, value any) *UpdateBuilder {
+	b.set = append(b.set, condition{sql: column + " = ?", args: []any{value}})
+	return b
+}
+
+// SetIf sets column to value if ok is true, for partial updates.
+func (b *UpdateBuilder) SetIf(ok bool, column string, value any) *UpdateBuilder {
+	if ok {
+		b.Set(column, value)
+	}
+	return b
+}
+
+// Where adds a condition as SelectBuilder.Where does.
+func (b *UpdateBuilder) Where(cond string, args ...any) *UpdateBuilder {
+	b.where.add(cond, args)
+	return b
+}
+
+// WhereIf adds a condition as Where does if ok is true.
+func (b *UpdateBuilder) WhereIf(ok bool, cond string, args ...any) *UpdateBuilder {
+	if ok {
+		b.where.add(cond, args)
+	}
+	return b
+}
+
+// All allows the statement to update every row when it has no conditions.
+func (b *UpdateBuilder) All() *UpdateBuilder {
+	b.all = true
+	return b
+}
+
+// Returning sets the columns returned by the statement, which are scanned with
+// Get or Select.
+func (b *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
+	b.returning = columns
+	return b
+}
+
+// Build returns the statement and its arguments, with placeholders for the
+// database type of q. Returns ErrUnbounded if there are no conditions, such as
+// when every WhereIf is skipped, unless All was called.
+func (b *UpdateBuilder) Build(q Querier) (string, []any, error) {
+	if b.where.err != nil {
+		return "", nil, b.where.err
+	}
+	if len(b.set) == 0 {
+		return "", nil, errors.New("database: update requires columns to set")
+	}
+	if len(b.where.list) == 0 && !b.all {
+		return "", nil, ErrUnbounded
+	}
+
+	var sb strings.Builder
+	var args []any
+
+	sb.WriteString("UPDATE " + b.table + " SET ")
+	for i, set := range b.set {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(set.sql)
+		args = append(args, set.args...)
+	}
+	b.where.write(&sb, &args)
+	returning(&sb, b.returning)
+
+	return sqlx.Rebind(bindType(q), sb.String()), args, nil
+}
+
+// Exec executes the statement and returns the number of rows affected.
+func (b *UpdateBuilder) Exec(ctx context.Context, q Querier) (int64, error) {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return 0, err
+	}
+	return Exec(ctx, q, query, args...)
+}
+
+// Get scans the single row returned by the statement into dest, returning
+// ErrNotFound if there is none.
+func (b *UpdateBuilder) Get(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return get(ctx, writer(ctx, q), dest, query, args)
+}
+
+// Select scans all rows returned by the statement into dest, a pointer to a slice.
+func (b *UpdateBuilder) Select(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, writer(ctx, q), dest, query, args...)
+}
+
+// DeleteBuilder builds a DELETE statement.
+type DeleteBuilder struct {
+	table     string
+	where     conditions
+	all       bool
+	returning []string
+}
+
+// Delete returns a builder for a DELETE statement from table.
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+// Where adds a condition as SelectBuilder.Where does.
+func (b *DeleteBuilder) Where(cond string, args ...any) *DeleteBuilder {
+	b.where.add(cond, args)
+	return b
+}
+
+// WhereIf adds a condition as Where does if ok is true.
+func (b *DeleteBuilder) WhereIf(ok bool, cond string, args ...any) *DeleteBuilder {
+	if ok {
+		b.where.add(cond, args)
+	}
+	return b
+}
+
+// All allows the statement to delete every row when it has no conditions.
+func (b *DeleteBuilder) All() *DeleteBuilder {
+	b.all = true
+	return b
+}
+
+// Returning sets the columns returned by the statement, which are scanned with
+// Get or Select.
+func (b *DeleteBuilder) Returning(columns ...string) *DeleteBuilder {
+	b.returning = columns
+	return b
+}
+
+// Build returns the statement and its arguments, with placeholders for the
+// database type of q. Returns ErrUnbounded if there are no conditions, such as
+// when every WhereIf is skipped, unless All was called.
+func (b *DeleteBuilder) Build(q Querier) (string, []any, error) {
+	if b.where.err != nil {
+		return "", nil, b.where.err
+	}
+	if len(b.where.list) == 0 && !b.all {
+		return "", nil, ErrUnbounded
+	}
+
+	var sb strings.Builder
+	var args []any
+
+	sb.WriteString("DELETE FROM " + b.table)
+	b.where.write(&sb, &args)
+	returning(&sb, b.returning)
+
+	return sqlx.Rebind(bindType(q), sb.String()), args, nil
+}
+
+// Exec executes the statement and returns the number of rows affected.
+func (b *DeleteBuilder) Exec(ctx context.Context, q Querier) (int64, error) {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return 0, err
+	}
+	return Exec(ctx, q, query, args...)
+}
+
+// Get scans the single row returned by the statement into dest, returning
+// ErrNotFound if there is none.
+func (b *DeleteBuilder) Get(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return get(ctx, writer(ctx, q), dest, query, args)
+}
+
+// Select scans all rows returned by the statement into dest, a pointer to a slice.
+func (b *DeleteBuilder) Select(ctx context.Context, q Querier, dest any) error {
+	query, args, err := b.Build(q)
+	if err != nil {
+		return err
+	}
+	return sqlx.SelectContext(ctx, writer(ctx, q), dest, query, args...)
+}
@@ -0,0 +1,144 @@
+package database_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dimmerz92/sittella/database"
+)
+
+func TestBuilderBuild(t *testing.T) {
+	db, _ := newDB(t)
+
+	tests := map[string]struct {
+		builder interface {
+			Build(q database.Querier) (string, []any, error)
+		}
+		query string
+		args  int
+	}{
+		"select": {
+			builder: database.Select("id", "name").From("users").
+				Join("JOIN posts ON posts.user_id = users.id AND posts.status = ?", "published").
+				Where("active = ?", true).
+				WhereIf(false, "name LIKE ?", "%a%").
+				Where("id IN (?)", []int{1, 2, 3}).
+				Where("role = :role", map[string]any{"role": "admin"}).
+				OrderBy("-created_at,name", "name", "created_at").
+				Limit(10).
+				Offset(20),
+			query: "SELECT id, name FROM users JOIN posts ON posts.user_id = users.id AND posts.status = ? WHERE (active = ?) AND (id IN (?, ?, ?)) AND (role = ?) ORDER BY created_at DESC, name ASC LIMIT 10 OFFSET 20",
+			args:  6,
+		},
+		"select offset": {
+			builder: database.Select("*").From("users").Offset(5),
+			query:   "SELECT * FROM users LIMIT -1 OFFSET 5",
+		},
+		"insert": {
+			builder: database.Insert("users").Columns("name", "email").Values("a", "a@example.com").Values("b", "b@example.com").Returning("id"),
+			query:   "INSERT INTO users (name, email) VALUES (?, ?), (?, ?) RETURNING id",
+			args:    4,
+		},
+		"update": {
+			builder: database.Update("users").Set("name", "a").SetIf(false, "email", "").Where("id = ?", 1).Returning("id", "name"),
+			query:   "UPDATE users SET name = ? WHERE (id = ?) RETURNING id, name",
+			args:    2,
+		},
+		"delete": {
+			builder: database.Delete("users").Where("id IN (?)", []int64{1, 2}),
+			query:   "DELETE FROM users WHERE (id IN (?, ?))",
+			args:    2,
+		},
+		"update all": {
+			builder: database.Update("users").Set("active", false).All(),
+			query:   "UPDATE users SET active = ?",
+			args:    1,
+		},
+		"delete all": {
+			builder: database.Delete("users").All(),
+			query:   "DELETE FROM users",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			query, args, err := test.builder.Build(db)
+			if err != nil {
+				t.Fatalf("failed to build: %v", err)
+			}
+			if query != test.query {
+				t.Fatalf("expected %q\ngot      %q", test.query, query)
+			}
+			if len(args) != test.args {
+				t.Fatalf("expected %d args got %d", test.args, len(args))
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, _, err := database.Select("id").From("users").OrderBy("password", "name").Build(db); !errors.Is(err, database.ErrInvalidOrder) {
+			t.Fatalf("expected %v got %v", database.ErrInvalidOrder, err)
+		}
+		if _, _, err := database.Select("id").From("users").Where("id IN (?)", []int{}).Build(db); err == nil {
+			t.Fatal("expected empty IN expansion to fail")
+		}
+		if _, _, err := database.Insert("users").Columns("name", "email").Values("a").Build(db); err == nil {
+			t.Fatal("expected mismatched values to fail")
+		}
+		if _, _, err := database.Update("users").Where("id = ?", 1).Build(db); err == nil {
+			t.Fatal("expected update without columns to fail")
+		}
+		if _, _, err := database.Update("users").Set("name", "a").WhereIf(false, "id = ?", 1).Build(db); !errors.Is(err, database.ErrUnbounded) {
+			t.Fatalf("expected %v got %v", database.ErrUnbounded, err)
+		}
+		if _, _, err := database.Delete("users").WhereIf(false, "id = ?", 1).Build(db); !errors.Is(err, database.ErrUnbounded) {
+			t.Fatalf("expected %v got %v", database.ErrUnbounded, err)
+		}
+	})
+}
+
+func TestBuilderRun(t *testing.T) {
+	db, _ := newDB(t)
+
+	var names []string
+	if err := database.Insert("items").Columns("name").Values("a").Values("b").Values("c").Returning("name").Select(t.Context(), db, &names); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("expected 3 inserted items got %v", names)
+	}
+
+	n, err := database.Update("items").Set("name", "z").Where("name IN (?)", []string{"a", "b"}).Exec(t.Context(), db)
+	if err != nil {
+		t.Fatalf("failed to update items: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows affected got %d", n)
+	}
+
+	search := "z"
+	b := database.Select("COUNT(*)").From("items").WhereIf(search != "", "name = :name", struct {
+		Name string `db:"name"`
+	}{search})
+
+	var count int
+	if err := b.Get(t.Context(), db, &count); err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 items got %d", count)
+	}
+
+	var name string
+	err = database.Delete("items").Where("name = ?", "missing").Returning("name").Get(t.Context(), db, &name)
+	if !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("expected %v got %v", database.ErrNotFound, err)
+	}
+
+	if err := database.Select("name").From("items").OrderBy("-name", "name").Limit(1).Get(t.Context(), db, &name); err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if name != "z" {
+		t.Fatalf("expected z got %s", name)
+	}
+}
@@ -0,0 +1,378 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimmerz92/sittella/database"
+)
+
+var ErrUnknownVersion = errors.New("migrations: unknown version")
+
+var ErrIrreversible = errors.New("migrations: migration has no down file")
+
+// Table specifies the name of the table applied migrations are recorded in.
+const Table = "schema_migrations"
+
+// schema creates the migrations table if it does not exist.
+const schema = `
+CREATE TABLE IF NOT EXISTS ` + Table + ` (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+// filename matches migration files such as 0001_create_users.up.sql.
+var filename = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// DriftError describes applied migrations that no longer match the migration files.
+type DriftError struct {
+	// Modified specifies the applied versions whose up file has changed.
+	Modified []int64
+
+	// Missing specifies the applied versions that have no up file.
+	Missing []int64
+}
+
+func (e *DriftError) Error() string {
+	var parts []string
+	if len(e.Modified) > 0 {
+		parts = append(parts, fmt.Sprintf("modified %v", e.Modified))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing %v", e.Missing))
+	}
+	return "migrations: applied migrations have drifted: " + strings.Join(parts, ", ")
+}
+
+// Migration defines a numbered schema change read from an up file and an
+// optional down file.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes the state of a migration in the database.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Modified is true if the migration was applied and its up file has since changed.
+	Modified bool
+
+	// Missing is true if the migration was applied but has no up file.
+	Missing bool
+}
+
+// applied describes a migration recorded in the migrations table.
+type applied struct {
+	Version   int64  `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt int64  `db:"applied_at"`
+}
+
+// Migrator applies and reverts migrations on a database.
+type Migrator struct {
+	db         *database.Database
+	migrations []Migration
+}
+
+// New returns a new Migrator for the migrations in the root of fsys.
+// Migration files are named {version}_{name}.up.sql and {version}_{name}.down.sql,
+// where version is a positive integer, and are applied in version order. Other
+// files are ignored.
+func New(db *database.Database, fsys fs.FS) (*Migrator, error) {
+	if db == nil {
+		return nil, errors.New("migrations.New: db required")
+	}
+
+	migrations, err := Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Parse reads the migrations in the root of fsys, ordered by version.
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := filename.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: invalid file name %q", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: invalid version in %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+			m.Checksum = checksum(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migrations: version %d has no up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	done, err := m.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Down reverts the n most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	done, err := m.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range slices.Backward(m.migrations) {
+		if n <= 0 {
+			break
+		}
+		if _, ok := done[migration.Version]; ok {
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+			n--
+		}
+	}
+
+	return nil
+}
+
+// To applies or reverts migrations so that exactly the migrations up to and
+// including version are applied. A version of zero reverts all migrations.
+// Returns ErrUnknownVersion if there is no migration with the given version.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(m Migration) bool { return m.Version == version }) {
+		return ErrUnknownVersion
+	}
+
+	done, err := m.check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range slices.Backward(m.migrations) {
+		if _, ok := done[migration.Version]; ok && migration.Version > version {
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok && migration.Version <= version {
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Status returns the state of all migrations, including applied migrations
+// that no longer have files, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if a, ok := done[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = time.Unix(0, a.AppliedAt)
+			status.Modified = a.Checksum != migration.Checksum
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, a := range done {
+		statuses = append(statuses, Status{
+			Version:   a.Version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: time.Unix(0, a.AppliedAt),
+			Missing:   true,
+		})
+	}
+
+	slices.SortFunc(statuses, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+
+	return statuses, nil
+}
+
+// applied returns the applied migrations by version, creating the migrations
+// table if it does not exist.
+func (m *Migrator) applied(ctx context.Context) (map[int64]applied, error) {
+	if _, err := m.db.ExecContext(ctx, schema); err != nil {
+		return nil, err
+	}
+
+	var rows []applied
+	if err := m.db.SelectContext(ctx, &rows, "SELECT version, name, checksum, applied_at FROM "+Table); err != nil {
+		return nil, err
+	}
+
+	done := make(map[int64]applied, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+
+	return done, nil
+}
+
+// check returns the applied migrations by version, or a DriftError if any of
+// them no longer match the migration files.
+func (m *Migrator) check(ctx context.Context) (map[int64]applied, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift DriftError
+	done := make(map[int64]applied)
+	for _, status := range statuses {
+		switch {
+		case status.Modified:
+			drift.Modified = append(drift.Modified, status.Version)
+		case status.Missing:
+			drift.Missing = append(drift.Missing, status.Version)
+		case status.Applied:
+			done[status.Version] = applied{Version: status.Version}
+		}
+	}
+
+	if len(drift.Modified) > 0 || len(drift.Missing) > 0 {
+		return nil, &drift
+	}
+
+	return done, nil
+}
+
+// apply runs the up migration and records it in a single transaction.
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	return m.inTx(ctx, migration, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, m.db.Rebind("INSERT INTO "+Table+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+			migration.Version, migration.Name, migration.Checksum, time.Now().UnixNano(),
+		)
+		return err
+	})
+}
+
+// revert runs the down migration and removes its record in a single transaction.
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("%w: version %d", ErrIrreversible, migration.Version)
+	}
+
+	return m.inTx(ctx, migration, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, m.db.Rebind("DELETE FROM "+Table+" WHERE version = ?"), migration.Version)
+		return err
+	})
+}
+
+// inTx runs fn in a transaction, annotating any error with the migration.
+func (m *Migrator) inTx(ctx context.Context, migration Migration, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return fmt.Errorf("migrations: version %d %s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// checksum returns the hex encoded SHA-256 checksum of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
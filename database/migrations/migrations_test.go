@@ -0,0 +1,211 @@
+package migrations_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/database/migrations"
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+)
+
+func newFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT; CREATE INDEX users_email_idx ON users (email);")},
+		"0002_add_email.down.sql":    {Data: []byte("DROP INDEX users_email_idx; ALTER TABLE users DROP COLUMN email;")},
+		"0003_create_posts.up.sql":   {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY);")},
+		"0003_create_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+		"README.md":                  {Data: []byte("ignored")},
+	}
+}
+
+func newDB(t *testing.T) *database.Database {
+	t.Helper()
+
+	db := sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3"))
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func applied(t *testing.T, m *migrations.Migrator) []int64 {
+	t.Helper()
+
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	var versions []int64
+	for _, status := range statuses {
+		if status.Applied {
+			versions = append(versions, status.Version)
+		}
+	}
+
+	return versions
+}
+
+func tableExists(t *testing.T, db *database.Database, name string) bool {
+	t.Helper()
+
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name); err != nil {
+		t.Fatalf("failed to query tables: %v", err)
+	}
+
+	return n > 0
+}
+
+func TestMigrations(t *testing.T) {
+	db := newDB(t)
+
+	m, err := migrations.New(db, newFS())
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	t.Run("up", func(t *testing.T) {
+		if err := m.Up(t.Context()); err != nil {
+			t.Fatalf("failed to migrate up: %v", err)
+		}
+
+		if got := applied(t, m); len(got) != 3 {
+			t.Fatalf("expected 3 applied migrations, got %v", got)
+		}
+
+		if _, err := db.Exec("INSERT INTO users (name, email) VALUES ('a', 'a@example.com')"); err != nil {
+			t.Fatalf("failed to use migrated table: %v", err)
+		}
+
+		if err := m.Up(t.Context()); err != nil {
+			t.Fatalf("expected repeated up to succeed: %v", err)
+		}
+	})
+
+	t.Run("down", func(t *testing.T) {
+		if err := m.Down(t.Context(), 2); err != nil {
+			t.Fatalf("failed to migrate down: %v", err)
+		}
+
+		if got := applied(t, m); len(got) != 1 || got[0] != 1 {
+			t.Fatalf("expected version 1 applied, got %v", got)
+		}
+
+		if tableExists(t, db, "posts") {
+			t.Fatal("expected posts table to be dropped")
+		}
+	})
+
+	t.Run("to", func(t *testing.T) {
+		if err := m.To(t.Context(), 3); err != nil {
+			t.Fatalf("failed to migrate to 3: %v", err)
+		}
+		if got := applied(t, m); len(got) != 3 {
+			t.Fatalf("expected 3 applied migrations, got %v", got)
+		}
+
+		if err := m.To(t.Context(), 0); err != nil {
+			t.Fatalf("failed to migrate to 0: %v", err)
+		}
+		if got := applied(t, m); len(got) != 0 {
+			t.Fatalf("expected no applied migrations, got %v", got)
+		}
+
+		if tableExists(t, db, "users") {
+			t.Fatal("expected users table to be dropped")
+		}
+
+		if err := m.To(t.Context(), 4); err != migrations.ErrUnknownVersion {
+			t.Fatalf("expected %v got %v", migrations.ErrUnknownVersion, err)
+		}
+	})
+}
+
+func TestMigrationsFailure(t *testing.T) {
+	db := newDB(t)
+
+	fsys := newFS()
+	fsys["0002_add_email.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD COLUMN email TEXT; INVALID SQL;")}
+
+	m, err := migrations.New(db, fsys)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	if err := m.Up(t.Context()); err == nil {
+		t.Fatal("expected migration to fail")
+	}
+
+	if got := applied(t, m); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected only version 1 applied, got %v", got)
+	}
+
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'email'"); err != nil {
+		t.Fatalf("failed to query columns: %v", err)
+	}
+	if n != 0 {
+		t.Fatal("expected failed migration to be rolled back")
+	}
+}
+
+func TestMigrationsDrift(t *testing.T) {
+	db := newDB(t)
+
+	m, err := migrations.New(db, newFS())
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	if err := m.Up(t.Context()); err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+
+	fsys := newFS()
+	fsys["0001_create_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")}
+	delete(fsys, "0003_create_posts.up.sql")
+	delete(fsys, "0003_create_posts.down.sql")
+
+	m, err = migrations.New(db, fsys)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	var drift *migrations.DriftError
+	if err := m.Up(t.Context()); !errors.As(err, &drift) {
+		t.Fatalf("expected drift error got %v", err)
+	}
+
+	if len(drift.Modified) != 1 || drift.Modified[0] != 1 || len(drift.Missing) != 1 || drift.Missing[0] != 3 {
+		t.Fatalf("unexpected drift %#v", drift)
+	}
+
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(statuses) != 3 || !statuses[0].Modified || !statuses[2].Missing {
+		t.Fatalf("unexpected statuses %#v", statuses)
+	}
+}
+
+func TestMigrationsParse(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"invalid name": {"create_users.up.sql": {}},
+		"missing up":   {"0001_create_users.down.sql": {}},
+		"conflicting":  {"0001_a.up.sql": {}, "0001_b.up.sql": {}},
+	} {
+		if _, err := migrations.Parse(fsys); err == nil {
+			t.Fatalf("expected %s to fail", name)
+		}
+	}
+
+	if _, err := migrations.Parse(fstest.MapFS{"0001_a.up.sql": {}}); err != nil {
+		t.Fatalf("expected irreversible migration to parse: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNotFound is returned by One when the query returns no rows. It wraps
+// sql.ErrNoRows so that either may be matched with errors.Is.
+var ErrNotFound error = notFoundError{}
+
+// notFoundError is the type of ErrNotFound.
+type notFoundError struct{}
+
+func (notFoundError) Error() string { return "database: not found" }
+
+func (notFoundError) Unwrap() error { return sql.ErrNoRows }
+
+// Querier is implemented by *Database and *Tx, so that the query helpers can run
+// on either.
+type Querier interface {
+	sqlx.ExtContext
+}
+
+// One returns the single row returned by query scanned into a T, which may be a
+// struct or a scannable type such as a string or int. Returns ErrNotFound if the
+// query returns no rows.
+//
+// If q is a *Database and ctx carries a transaction, the query runs in that
+// transaction instead, as it does for all query helpers.
+func One[T any](ctx context.Context, q Querier, query string, args ...any) (T, error) {
+	var v T
+	err := get(ctx, querier(ctx, q), &v, query, args)
+	return v, err
+}
+
+// All returns all rows returned by query scanned into a slice of T.
+func All[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	var v []T
+	if err := sqlx.SelectContext(ctx, querier(ctx, q), &v, query, args...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Iter returns an iterator over the rows returned by query, scanning one row
+// into a T at a time so the result set is never held in memory. The query runs
+// when iteration starts, and an error ends the iteration. The rows are closed
+// when iteration ends, including when the loop is exited early.
+func Iter[T any](ctx context.Context, q Querier, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		rows, err := querier(ctx, q).QueryxContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		structScan := !scannable(reflect.TypeFor[T]())
+		for rows.Next() {
+			var v T
+			if structScan {
+				err = rows.StructScan(&v)
+			} else {
+				err = rows.Scan(&v)
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if !yield(v, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// Exists returns true if query returns at least one row.
+func Exists(ctx context.Context, q Querier, query string, args ...any) (bool, error) {
+	var exists bool
+	err := sqlx.GetContext(ctx, querier(ctx, q), &exists, "SELECT EXISTS ("+query+")", args...)
+	return exists, err
+}
+
+// Exec executes query and returns the number of rows affected.
+func Exec(ctx context.Context, q Querier, query string, args ...any) (int64, error) {
+	res, err := querier(ctx, q).ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// get scans a single row into dest, mapping sql.ErrNoRows to ErrNotFound.
+func get(ctx context.Context, q sqlx.QueryerContext, dest any, query string, args []any) error {
+	err := sqlx.GetContext(ctx, q, dest, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// querier returns the transaction carried by ctx if q is a *Database, and q
+// otherwise.
+func querier(ctx context.Context, q Querier) Querier {
+	if _, ok := q.(*Database); ok {
+		if tx, ok := TxFromContext(ctx); ok {
+			return tx
+		}
+	}
+	return q
+}
+
+// scannerType is the sql.Scanner interface type.
+var scannerType = reflect.TypeFor[sql.Scanner]()
+
+// scannable returns true if a row is scanned directly into a t rather than
+// into its fields, matching sqlx: t is not a struct, implements sql.Scanner or
+// has no exported fields (e.g. time.Time).
+func scannable(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(scannerType) || t.Kind() != reflect.Struct {
+		return true
+	}
+
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/dimmerz92/sittella/database"
+)
+
+type item struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestQueryHelpers(t *testing.T) {
+	db, _ := newDB(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := db.Exec("INSERT INTO items (name) VALUES (?)", name); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	t.Run("one", func(t *testing.T) {
+		got, err := database.One[item](t.Context(), db, "SELECT rowid AS id, name FROM items WHERE name = ?", "b")
+		if err != nil {
+			t.Fatalf("failed to get item: %v", err)
+		}
+		if got.ID != 2 || got.Name != "b" {
+			t.Fatalf("unexpected item %+v", got)
+		}
+
+		n, err := database.One[int](t.Context(), db, "SELECT COUNT(*) FROM items")
+		if err != nil || n != 3 {
+			t.Fatalf("expected 3 items got %d: %v", n, err)
+		}
+
+		_, err = database.One[item](t.Context(), db, "SELECT rowid AS id, name FROM items WHERE name = ?", "z")
+		if !errors.Is(err, database.ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("expected %v got %v", database.ErrNotFound, err)
+		}
+		if err.Error() != "database: not found" {
+			t.Fatalf("unexpected error message %q", err.Error())
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		got, err := database.All[string](t.Context(), db, "SELECT name FROM items ORDER BY name")
+		if err != nil {
+			t.Fatalf("failed to get items: %v", err)
+		}
+		if len(got) != 3 || got[0] != "a" || got[2] != "c" {
+			t.Fatalf("expected [a b c] got %v", got)
+		}
+	})
+
+	t.Run("iter", func(t *testing.T) {
+		var names []string
+		for it, err := range database.Iter[item](t.Context(), db, "SELECT rowid AS id, name FROM items ORDER BY name") {
+			if err != nil {
+				t.Fatalf("failed to iterate items: %v", err)
+			}
+			names = append(names, it.Name)
+			if len(names) == 2 {
+				break
+			}
+		}
+		if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Fatalf("expected [a b] got %v", names)
+		}
+
+		var n int
+		for _, err := range database.Iter[string](t.Context(), db, "SELECT missing FROM items") {
+			if err == nil {
+				t.Fatal("expected invalid query to fail")
+			}
+			n++
+		}
+		if n != 1 {
+			t.Fatalf("expected a single error, got %d results", n)
+		}
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		for name, expected := range map[string]bool{"a": true, "z": false} {
+			exists, err := database.Exists(t.Context(), db, "SELECT 1 FROM items WHERE name = ?", name)
+			if err != nil {
+				t.Fatalf("failed to check existence: %v", err)
+			}
+			if exists != expected {
+				t.Fatalf("expected %s exists to be %v", name, expected)
+			}
+		}
+	})
+
+	t.Run("exec in transaction", func(t *testing.T) {
+		err := db.WithTx(t.Context(), func(tx *database.Tx) error {
+			n, err := database.Exec(t.Context(), tx, "UPDATE items SET name = name || '!' WHERE name != ?", "a")
+			if err != nil {
+				return err
+			}
+			if n != 2 {
+				t.Fatalf("expected 2 rows affected got %d", n)
+			}
+
+			// the database runs queries in the transaction carried by the context.
+			ctx := database.ContextWithTx(t.Context(), tx)
+			exists, err := database.Exists(ctx, db, "SELECT 1 FROM items WHERE name = 'b!'")
+			if err != nil {
+				return err
+			}
+			if !exists {
+				t.Fatal("expected uncommitted update to be visible in the transaction")
+			}
+
+			return errors.New("rollback")
+		})
+		if err == nil {
+			t.Fatal("expected transaction to roll back")
+		}
+
+		if exists, _ := database.Exists(t.Context(), db, "SELECT 1 FROM items WHERE name = 'b!'"); exists {
+			t.Fatal("expected update to be rolled back")
+		}
+	})
+}
@@ -4,22 +4,60 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/dimmerz92/sittella/database"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	DEFAULT_DSN = "data/db.sqlite3?_fk=true&_sync=normal&_timeout=5000&_journal=wal"
+	DEFAULT_DSN = "data/db.sqlite3?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=foreign_keys(1)"
 	MEMORY_DSN  = "file:memorydb?mode=memory&cache=shared"
 )
 
+// New returns a new database connected with dsn, creating the parent directory
+// of a file database if it does not exist. Panics on failure; use Open to
+// configure the database with Options and handle errors.
 func New(dsn string) *database.Database {
-	if dsn == DEFAULT_DSN {
-		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
-			panic(fmt.Sprintf("sqlite.New: %v", err))
-		}
+	if err := mkdir(dsn); err != nil {
+		panic(fmt.Sprintf("sqlite.New: %v", err))
 	}
 
 	return database.New(database.SQLITE, "sqlite", dsn)
 }
+
+// NewSplit returns a new database on the file at dsn with a single connection
+// writer pool and a read only pool of up to readers connections, so writers do
+// not contend for the write lock and readers do not queue behind them. The
+// database should use WAL journal mode (e.g. "_pragma=journal_mode(WAL)") for
+// readers to run concurrently with the writer.
+func NewSplit(dsn string, readers int) *database.Database {
+	if err := mkdir(dsn); err != nil {
+		panic(fmt.Sprintf("sqlite.NewSplit: %v", err))
+	}
+
+	db := database.NewSplit(database.SQLITE, "sqlite", dsn, withPragma(dsn, "query_only(true)"))
+
+	db.Writer().SetMaxOpenConns(1)
+	db.Reader().SetMaxOpenConns(max(readers, 1))
+	db.Reader().SetMaxIdleConns(max(readers, 1))
+
+	return db
+}
+
+// mkdir creates the parent directory of the database file named by dsn, unless
+// dsn names an in memory database.
+func mkdir(dsn string) error {
+	if inMemory(dsn) {
+		return nil
+	}
+
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
+
+// inMemory returns true if dsn names an in memory database.
+func inMemory(dsn string) bool {
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	return path == "" || path == MemoryPath || strings.Contains(query, "mode=memory")
+}
@@ -1,24 +1,28 @@
 package sqlitedb_test
 
 import (
-	"database/sql/driver"
+	"context"
+	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/dimmerz92/sittella/database"
 	"github.com/dimmerz92/sittella/database/sqlitedb"
 	"github.com/google/uuid"
-	"modernc.org/sqlite"
 )
 
 func TestSQLiteDatabase(t *testing.T) {
-	sqlite.RegisterScalarFunction("uuid", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
-		return uuid.NewString(), nil
-	})
-
 	t.Run("in memory", func(t *testing.T) {
-		db := sqlitedb.New(sqlitedb.MEMORY_DSN)
+		db, err := sqlitedb.Open(t.Context(), sqlitedb.Options{Path: sqlitedb.MEMORY_DSN, Builtins: true})
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
 		defer db.Close()
 
 		var out string
@@ -46,3 +50,277 @@ func TestSQLiteDatabase(t *testing.T) {
 		}
 	})
 }
+
+func TestSQLiteSplit(t *testing.T) {
+	db := sqlitedb.NewSplit(filepath.Join(t.TempDir(), "db.sqlite3")+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", 4)
+	defer db.Close()
+
+	if db.Reader() == db.Writer() {
+		t.Fatal("expected separate reader and writer pools")
+	}
+
+	if _, err := db.Exec("CREATE TABLE items (name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	if err := db.WithTx(t.Context(), func(tx *database.Tx) error {
+		_, err := tx.Exec("INSERT INTO items (name) VALUES ('a')")
+		return err
+	}); err != nil {
+		t.Fatalf("failed to write in transaction: %v", err)
+	}
+
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM items"); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 item, got %d", n)
+	}
+
+	if _, err := db.Reader().Exec("INSERT INTO items (name) VALUES ('b')"); err == nil {
+		t.Fatal("expected write on reader pool to fail")
+	}
+
+	// read-only transactions must leave the reader connection query only; a
+	// single reader connection ensures the write below reuses it.
+	db.Reader().SetMaxOpenConns(1)
+
+	tx, err := db.NewTx(t.Context(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to begin read-only transaction: %v", err)
+	}
+	if err := tx.Get(&n, "SELECT COUNT(*) FROM items"); err != nil {
+		t.Fatalf("failed to read in transaction: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	if _, err := db.Reader().Exec("INSERT INTO items (name) VALUES ('c')"); err == nil {
+		t.Fatal("expected write on reader pool to fail after a read-only transaction")
+	}
+}
+
+func TestSQLiteOpen(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		opts := sqlitedb.DefaultOptions(filepath.Join(t.TempDir(), "nested", "dir", "db.sqlite3"))
+		opts.CacheSize = -4000
+		opts.MmapSize = 1 << 20
+		opts.Pragmas = []string{"temp_store(MEMORY)"}
+
+		db, err := sqlitedb.Open(t.Context(), opts)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		if _, err := os.Stat(opts.Path); err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+
+		for pragma, expected := range map[string]string{
+			"journal_mode": "wal",
+			"synchronous":  "1",
+			"busy_timeout": "5000",
+			"foreign_keys": "1",
+			"cache_size":   "-4000",
+			"temp_store":   "2",
+		} {
+			var got string
+			if err := db.Get(&got, "PRAGMA "+pragma); err != nil {
+				t.Fatalf("failed to read %s: %v", pragma, err)
+			}
+			if got != expected {
+				t.Fatalf("expected %s %s got %s", pragma, expected, got)
+			}
+		}
+
+		if db.Reader() == db.Writer() {
+			t.Fatal("expected separate reader and writer pools")
+		}
+		if n := db.Reader().Stats().MaxOpenConnections; n != opts.MaxOpenConns {
+			t.Fatalf("expected %d reader connections got %d", opts.MaxOpenConns, n)
+		}
+		if n := db.Writer().Stats().MaxOpenConnections; n != 1 {
+			t.Fatalf("expected 1 writer connection got %d", n)
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		db, err := sqlitedb.Open(t.Context(), sqlitedb.Options{Path: sqlitedb.MemoryPath, ForeignKeys: true, MaxOpenConns: 4})
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		if _, err := db.Exec("CREATE TABLE items (name TEXT NOT NULL)"); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		var n int
+		if err := db.Get(&n, "SELECT COUNT(*) FROM items"); err != nil {
+			t.Fatalf("expected table to be visible on every connection: %v", err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for name, opts := range map[string]sqlitedb.Options{
+			"no path":             {},
+			"invalid synchronous": {Path: filepath.Join(t.TempDir(), "db.sqlite3"), Synchronous: "SOMETIMES"},
+			"not applied":         {Path: sqlitedb.MemoryPath, JournalMode: "WAL"},
+		} {
+			if db, err := sqlitedb.Open(t.Context(), opts); err == nil {
+				db.Close()
+				t.Fatalf("expected %s to fail", name)
+			}
+		}
+	})
+}
+
+func TestSQLiteNewCreatesDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "db.sqlite3")
+
+	db := sqlitedb.New(path + "?_pragma=busy_timeout(5000)")
+	defer db.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+}
+
+// benchmarkPool runs a read heavy workload across parallel goroutines with one
+// read-then-write transaction in every writeEvery operations, reporting the
+// transactions that failed after exhausting their busy retries.
+// Transactions are short, so the split pool gains little here.
+func benchmarkPool(b *testing.B, db *database.Database, writeEvery int64) {
+	b.Helper()
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		b.Fatalf("failed to create table: %v", err)
+	}
+	for i := range 1000 {
+		if _, err := db.Exec("INSERT INTO items (name) VALUES (?)", fmt.Sprint(i)); err != nil {
+			b.Fatalf("failed to seed table: %v", err)
+		}
+	}
+
+	var ops, failed atomic.Int64
+
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if ops.Add(1)%writeEvery == 0 {
+				err := db.WithTx(context.Background(), func(tx *database.Tx) error {
+					var n int
+					if err := tx.Get(&n, "SELECT COUNT(*) FROM items"); err != nil {
+						return err
+					}
+					_, err := tx.Exec("INSERT INTO items (name) VALUES (?)", fmt.Sprint(n))
+					return err
+				})
+				if database.IsBusy(err) {
+					failed.Add(1)
+				} else if err != nil {
+					b.Errorf("failed to write: %v", err)
+					return
+				}
+				continue
+			}
+
+			var n int
+			if err := db.Get(&n, "SELECT COUNT(*) FROM items WHERE id > 500"); err != nil {
+				b.Errorf("failed to read: %v", err)
+				return
+			}
+		}
+	})
+
+	b.ReportMetric(float64(failed.Load())/float64(b.N), "failed/op")
+}
+
+func BenchmarkSQLitePool(b *testing.B) {
+	dsn := func() string {
+		return filepath.Join(b.TempDir(), "db.sqlite3") + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+	}
+
+	for _, writeEvery := range []int64{2, 10} {
+		b.Run(fmt.Sprintf("shared/write-1-in-%d", writeEvery), func(b *testing.B) {
+			benchmarkPool(b, sqlitedb.New(dsn()), writeEvery)
+		})
+
+		b.Run(fmt.Sprintf("split/write-1-in-%d", writeEvery), func(b *testing.B) {
+			benchmarkPool(b, sqlitedb.NewSplit(dsn(), 8), writeEvery)
+		})
+	}
+}
+
+// benchmarkReadsDuringWrites measures point reads on a pool of four connections
+// while eight goroutines continuously run write transactions that each hold the
+// write lock for a millisecond.
+func benchmarkReadsDuringWrites(b *testing.B, split bool) {
+	b.Helper()
+
+	opts := sqlitedb.DefaultOptions(filepath.Join(b.TempDir(), "db.sqlite3"))
+	opts.SplitReadWrite = split
+	opts.MaxOpenConns = 4
+	opts.MaxIdleConns = 4
+
+	db, err := sqlitedb.Open(b.Context(), opts)
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		b.Fatalf("failed to create table: %v", err)
+	}
+	for i := range 1000 {
+		if _, err := db.Exec("INSERT INTO items (name) VALUES (?)", fmt.Sprint(i)); err != nil {
+			b.Fatalf("failed to seed table: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var writers sync.WaitGroup
+	for range 8 {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for ctx.Err() == nil {
+				db.WithTx(ctx, func(tx *database.Tx) error {
+					if _, err := tx.Exec("INSERT INTO items (name) VALUES ('write')"); err != nil {
+						return err
+					}
+					time.Sleep(time.Millisecond)
+					return nil
+				})
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var id int
+		for pb.Next() {
+			id = id%1000 + 1
+
+			var name string
+			if err := db.Get(&name, "SELECT name FROM items WHERE id = ?", id); err != nil {
+				b.Errorf("failed to read: %v", err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+
+	cancel()
+	writers.Wait()
+}
+
+func BenchmarkSQLiteReadsDuringWrites(b *testing.B) {
+	b.Run("shared", func(b *testing.B) { benchmarkReadsDuringWrites(b, false) })
+	b.Run("split", func(b *testing.B) { benchmarkReadsDuringWrites(b, true) })
+}
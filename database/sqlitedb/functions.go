@@ -0,0 +1,335 @@
+package sqlitedb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+)
+
+// Function defines an application defined SQL function. Functions are created
+// with Scalar or Aggregate and registered with Options.Functions or Register.
+type Function struct {
+	name string
+	impl *sqlite.FunctionImpl
+}
+
+// Name returns the SQL name of the function.
+func (f *Function) Name() string {
+	return f.name
+}
+
+// Scalar returns a scalar function called name that accepts nargs arguments,
+// or any number of arguments if nargs is negative. Deterministic functions
+// must always return the same result for the same arguments, which allows them
+// to be used in indexes and partial index WHERE clauses.
+//
+// Arguments are nil, int64, float64, string or []byte. The result must be one
+// of those types, bool or time.Time.
+func Scalar(name string, nargs int32, deterministic bool, fn func(args []driver.Value) (driver.Value, error)) *Function {
+	return &Function{
+		name: name,
+		impl: &sqlite.FunctionImpl{
+			NArgs:         nargs,
+			Deterministic: deterministic,
+			Scalar: func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				return fn(args)
+			},
+		},
+	}
+}
+
+// Aggregate returns an aggregate function called name that accepts nargs
+// arguments, or any number of arguments if nargs is negative. Each evaluation
+// starts with a zero S, calls step for each row and returns the result of
+// final.
+func Aggregate[S any](name string, nargs int32, step func(state *S, args []driver.Value) error, final func(state *S) (driver.Value, error)) *Function {
+	return &Function{
+		name: name,
+		impl: &sqlite.FunctionImpl{
+			NArgs: nargs,
+			MakeAggregate: func(sqlite.FunctionContext) (sqlite.AggregateFunction, error) {
+				return &aggregate[S]{step: step, final: final}, nil
+			},
+		},
+	}
+}
+
+// aggregate adapts the step and final functions of an Aggregate to an evaluation.
+type aggregate[S any] struct {
+	state S
+	step  func(state *S, args []driver.Value) error
+	final func(state *S) (driver.Value, error)
+}
+
+func (a *aggregate[S]) Step(_ *sqlite.FunctionContext, args []driver.Value) error {
+	return a.step(&a.state, args)
+}
+
+func (a *aggregate[S]) WindowInverse(*sqlite.FunctionContext, []driver.Value) error {
+	return errors.New("sqlitedb: aggregate does not support window frames")
+}
+
+func (a *aggregate[S]) WindowValue(*sqlite.FunctionContext) (driver.Value, error) {
+	return a.final(&a.state)
+}
+
+func (a *aggregate[S]) Final(*sqlite.FunctionContext) {}
+
+// registered holds the functions registered with the SQLite driver by name.
+var registered = struct {
+	sync.Mutex
+	functions map[string]*Function
+}{functions: make(map[string]*Function)}
+
+// Register registers functions with the SQLite driver, returning an error if a
+// different Function was already registered under the same name.
+//
+// The driver has no way to scope functions to a database: they are available to
+// every connection it opens afterwards, in every database of the process. A name
+// may therefore only be bound to one Function for the life of the process, and
+// registering the same Function again is a no-op. Open registers the functions
+// in Options; otherwise register them once at startup, before opening the
+// databases that use them, as connections that are already open do not see them.
+func Register(functions ...*Function) error {
+	registered.Lock()
+	defer registered.Unlock()
+
+	for _, fn := range functions {
+		if existing, ok := registered.functions[fn.name]; ok {
+			if existing != fn {
+				return fmt.Errorf("sqlitedb: function %s already registered", fn.name)
+			}
+			continue
+		}
+
+		if err := sqlite.RegisterFunction(fn.name, fn.impl); err != nil {
+			return fmt.Errorf("sqlitedb: register %s: %w", fn.name, err)
+		}
+		registered.functions[fn.name] = fn
+	}
+
+	return nil
+}
+
+// Builtins specifies the built in functions, registered with Options.Builtins
+// or Register(Builtins...):
+//
+//   - uuid() returns a random (version 4) UUID.
+//   - uuid_v7() returns a time ordered (version 7) UUID.
+//   - ulid() returns a time ordered ULID.
+//   - regexp(pattern, text) reports whether text matches the Go regular
+//     expression pattern, enabling the "text REGEXP pattern" operator.
+//   - unicode_lower(text) and unicode_upper(text) change the case of all
+//     Unicode letters, unlike the built in lower and upper which only change
+//     ASCII letters.
+//   - casefold(text) returns text with Unicode case folding applied, for case
+//     insensitive comparisons.
+//   - json_canonical(json) returns json re-encoded with sorted object keys and
+//     no insignificant whitespace, for comparing documents.
+//   - json_array_contains(json, value) reports whether the JSON array json
+//     contains value.
+var Builtins = []*Function{
+	Scalar("uuid", 0, false, func([]driver.Value) (driver.Value, error) {
+		return uuid.NewString(), nil
+	}),
+	Scalar("uuid_v7", 0, false, func([]driver.Value) (driver.Value, error) {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+		return id.String(), nil
+	}),
+	Scalar("ulid", 0, false, func([]driver.Value) (driver.Value, error) {
+		return newULID(time.Now())
+	}),
+	Scalar("regexp", 2, true, func(args []driver.Value) (driver.Value, error) {
+		pattern, ok := text(args[0])
+		if !ok {
+			return nil, errors.New("regexp: pattern must be text")
+		}
+		s, ok := text(args[1])
+		if !ok {
+			return nil, nil
+		}
+
+		re, err := compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}),
+	Scalar("unicode_lower", 1, true, textFunc(strings.ToLower)),
+	Scalar("unicode_upper", 1, true, textFunc(strings.ToUpper)),
+	Scalar("casefold", 1, true, textFunc(casefold)),
+	Scalar("json_canonical", 1, true, func(args []driver.Value) (driver.Value, error) {
+		s, ok := text(args[0])
+		if !ok {
+			return nil, nil
+		}
+
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("json_canonical: %w", err)
+		}
+
+		// maps are encoded with sorted keys.
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}),
+	Scalar("json_array_contains", 2, true, func(args []driver.Value) (driver.Value, error) {
+		s, ok := text(args[0])
+		if !ok {
+			return nil, nil
+		}
+
+		var values []any
+		if err := json.Unmarshal([]byte(s), &values); err != nil {
+			return nil, fmt.Errorf("json_array_contains: %w", err)
+		}
+
+		for _, v := range values {
+			if equal(v, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}),
+}
+
+// text returns v as a string if it is text or a blob.
+func text(v driver.Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+		return "", false
+	}
+}
+
+// textFunc returns a scalar implementation applying fn to a single text
+// argument, returning NULL for NULL.
+func textFunc(fn func(string) string) func(args []driver.Value) (driver.Value, error) {
+	return func(args []driver.Value) (driver.Value, error) {
+		s, ok := text(args[0])
+		if !ok {
+			return args[0], nil
+		}
+		return fn(s), nil
+	}
+}
+
+// casefold returns s with each rune mapped to the lower case of its upper case,
+// so that runes with several case forms (e.g. "ſ", "s" and "S") fold to the
+// same rune.
+func casefold(s string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+}
+
+// equal reports whether the decoded JSON value v equals the SQL value sql.
+func equal(v any, sql driver.Value) bool {
+	switch sql := sql.(type) {
+	case nil:
+		return v == nil
+	case int64:
+		f, ok := v.(float64)
+		return ok && f == float64(sql)
+	case float64:
+		f, ok := v.(float64)
+		return ok && f == sql
+	case string:
+		s, ok := v.(string)
+		return ok && s == sql
+	case []byte:
+		s, ok := v.(string)
+		return ok && s == string(sql)
+	default:
+		return false
+	}
+}
+
+// maxPatterns specifies how many compiled regexp patterns are cached.
+const maxPatterns = 128
+
+// patterns caches compiled regexp patterns, as a query evaluates the same
+// pattern for every row.
+var patterns = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+// compile returns the compiled pattern, using the cache if possible.
+func compile(pattern string) (*regexp.Regexp, error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+
+	if re, ok := patterns.m[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns.m) >= maxPatterns {
+		clear(patterns.m)
+	}
+	patterns.m[pattern] = re
+
+	return re, nil
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID for t: a 48 bit millisecond timestamp followed by 80
+// random bits, encoded as 26 Crockford base32 characters.
+func newULID(t time.Time) (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	out.Grow(26)
+
+	// 128 bits are encoded as 26 characters of 5 bits, with the first
+	// character holding the 3 most significant bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		shift := uint(i * 5)
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift > 59:
+			v = lo>>shift | hi<<(64-shift)
+		default:
+			v = lo >> shift
+		}
+		out.WriteByte(crockford[v&0x1f])
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,182 @@
+package sqlitedb_test
+
+import (
+	"database/sql/driver"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+	"github.com/google/uuid"
+)
+
+var reverse = sqlitedb.Scalar("test_reverse", 1, true, func(args []driver.Value) (driver.Value, error) {
+	s, _ := args[0].(string)
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r), nil
+})
+
+var product = sqlitedb.Aggregate("test_product", 1,
+	func(state *int64, args []driver.Value) error {
+		if *state == 0 {
+			*state = 1
+		}
+		*state *= args[0].(int64)
+		return nil
+	},
+	func(state *int64) (driver.Value, error) {
+		return *state, nil
+	},
+)
+
+func newFunctionsDB(t *testing.T) *database.Database {
+	t.Helper()
+
+	opts := sqlitedb.DefaultOptions(filepath.Join(t.TempDir(), "db.sqlite3"))
+	opts.Functions = []*sqlitedb.Function{reverse, product}
+
+	db, err := sqlitedb.Open(t.Context(), opts)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestBuiltins(t *testing.T) {
+	db := newFunctionsDB(t)
+
+	query := func(t *testing.T, query string, args ...any) string {
+		t.Helper()
+
+		var out string
+		if err := db.Get(&out, query, args...); err != nil {
+			t.Fatalf("failed to run %q: %v", query, err)
+		}
+		return out
+	}
+
+	t.Run("uuid", func(t *testing.T) {
+		for fn, version := range map[string]uuid.Version{"uuid": 4, "uuid_v7": 7} {
+			id, err := uuid.Parse(query(t, "SELECT "+fn+"()"))
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", fn, err)
+			}
+			if id.Version() != version {
+				t.Fatalf("expected %s version %d got %d", fn, version, id.Version())
+			}
+		}
+	})
+
+	t.Run("ulid", func(t *testing.T) {
+		a, b := query(t, "SELECT ulid()"), query(t, "SELECT ulid()")
+		if !regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(a) {
+			t.Fatalf("invalid ulid %q", a)
+		}
+		if a == b {
+			t.Fatal("expected unique ulids")
+		}
+		if a[:8] > b[:8] {
+			t.Fatalf("expected time ordered ulids, got %s then %s", a, b)
+		}
+	})
+
+	t.Run("regexp", func(t *testing.T) {
+		if got := query(t, "SELECT 'abc123' REGEXP '^[a-z]+\\d+$'"); got != "1" {
+			t.Fatalf("expected match got %s", got)
+		}
+		if got := query(t, "SELECT 'abc' REGEXP '^\\d+$'"); got != "0" {
+			t.Fatalf("expected no match got %s", got)
+		}
+		if err := db.Get(new(string), "SELECT 'abc' REGEXP '('"); err == nil {
+			t.Fatal("expected invalid pattern to fail")
+		}
+	})
+
+	t.Run("case", func(t *testing.T) {
+		if got := query(t, "SELECT unicode_lower('ÀÉÎ')"); got != "àéî" {
+			t.Fatalf("expected àéî got %s", got)
+		}
+		if got := query(t, "SELECT unicode_upper('àéî')"); got != "ÀÉÎ" {
+			t.Fatalf("expected ÀÉÎ got %s", got)
+		}
+		if got := query(t, "SELECT casefold('Straſe') = casefold('STRASE')"); got != "1" {
+			t.Fatalf("expected folded strings to be equal")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		if got := query(t, `SELECT json_canonical('{ "b": 1, "a": [true, null] }')`); got != `{"a":[true,null],"b":1}` {
+			t.Fatalf("unexpected canonical json %s", got)
+		}
+		if got := query(t, `SELECT json_array_contains('["a", 2]', 'a') AND json_array_contains('["a", 2]', 2)`); got != "1" {
+			t.Fatal("expected array to contain values")
+		}
+		if got := query(t, `SELECT json_array_contains('["a", 2]', 'b')`); got != "0" {
+			t.Fatal("expected array not to contain value")
+		}
+	})
+}
+
+func TestFunctions(t *testing.T) {
+	db := newFunctionsDB(t)
+
+	var out string
+	if err := db.Get(&out, "SELECT test_reverse('héllo')"); err != nil {
+		t.Fatalf("failed to run scalar function: %v", err)
+	}
+	if out != "olléh" {
+		t.Fatalf("expected olléh got %s", out)
+	}
+
+	if _, err := db.Exec("CREATE TABLE items (n INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO items (n) VALUES (2), (3), (4)"); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+
+	var n int64
+	if err := db.Get(&n, "SELECT test_product(n) FROM items"); err != nil {
+		t.Fatalf("failed to run aggregate function: %v", err)
+	}
+	if n != 24 {
+		t.Fatalf("expected 24 got %d", n)
+	}
+
+	// opening another database with the same functions is allowed.
+	newFunctionsDB(t)
+
+	// the driver cannot scope functions, so they are available to every database.
+	other, err := sqlitedb.Open(t.Context(), sqlitedb.Options{Path: sqlitedb.MemoryPath})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer other.Close()
+
+	if err := other.Get(&out, "SELECT test_reverse('abc')"); err != nil || out != "cba" {
+		t.Fatalf("expected function in every database, got %q: %v", out, err)
+	}
+
+	conflict := sqlitedb.Scalar("test_reverse", 1, true, func(args []driver.Value) (driver.Value, error) {
+		return args[0], nil
+	})
+
+	_, err = sqlitedb.Open(t.Context(), sqlitedb.Options{
+		Path:      filepath.Join(t.TempDir(), "db.sqlite3"),
+		Functions: []*sqlitedb.Function{conflict},
+	})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected conflicting function to fail, got %v", err)
+	}
+
+	if err := sqlitedb.Register(conflict); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected conflicting function to fail, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package sqlitedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/jmoiron/sqlx"
+)
+
+// MemoryPath specifies an in memory database when used as Options.Path.
+const MemoryPath = ":memory:"
+
+// Options specifies the settings used to open an SQLite database.
+// Zero values leave the SQLite default in place.
+type Options struct {
+	// Path specifies the database file path, or MemoryPath for a private in
+	// memory database. Parent directories are created if they do not exist.
+	// In memory databases use a single connection.
+	Path string
+
+	// JournalMode specifies the journal mode (e.g. "WAL", "DELETE").
+	JournalMode string
+
+	// Synchronous specifies the synchronous setting ("OFF", "NORMAL", "FULL" or "EXTRA").
+	Synchronous string
+
+	// BusyTimeout specifies how long to wait for a locked database before
+	// returning a busy error.
+	BusyTimeout time.Duration
+
+	// ForeignKeys enables foreign key constraint enforcement.
+	ForeignKeys bool
+
+	// CacheSize specifies the page cache size; positive values are pages and
+	// negative values are KiB.
+	CacheSize int
+
+	// MmapSize specifies the maximum number of bytes used for memory mapped I/O.
+	MmapSize int64
+
+	// SplitReadWrite opens a single connection writer pool and a separate read
+	// only pool, to which MaxOpenConns and MaxIdleConns then apply.
+	//
+	// Writers waiting for the write lock then queue for the writer connection
+	// rather than occupying connections readers need, which keeps reads fast
+	// while writes are in progress (see BenchmarkSQLiteReadsDuringWrites). When
+	// writes are rare and short, the extra pool adds a little overhead instead
+	// (see BenchmarkSQLitePool).
+	SplitReadWrite bool
+
+	// MaxOpenConns specifies the maximum number of open connections in the pool.
+	MaxOpenConns int
+
+	// MaxIdleConns specifies the maximum number of idle connections in the pool.
+	MaxIdleConns int
+
+	// Builtins registers the Builtins functions.
+	Builtins bool
+
+	// Functions specifies application defined functions to register, created
+	// with Scalar or Aggregate.
+	//
+	// The SQLite driver cannot scope functions to the connections of one
+	// database: Open registers them with Register, so they are also available to
+	// every other SQLite database in the process, and Open returns an error if a
+	// different Function was already registered under the same name.
+	Functions []*Function
+
+	// Pragmas specifies additional pragmas applied to each connection, written
+	// as they are passed to _pragma (e.g. "temp_store(MEMORY)"). They are not
+	// validated.
+	Pragmas []string
+}
+
+// DefaultOptions returns the recommended options for a file database at path:
+// WAL journaling, NORMAL synchronous, a 5 second busy timeout, foreign keys and
+// split reader and writer pools, with the Builtins functions registered.
+// The pools are split as it bounds read latency under concurrent writes, at a
+// small cost to workloads that rarely write.
+func DefaultOptions(path string) Options {
+	return Options{
+		Path:           path,
+		Builtins:       true,
+		JournalMode:    "WAL",
+		Synchronous:    "NORMAL",
+		BusyTimeout:    5 * time.Second,
+		ForeignKeys:    true,
+		SplitReadWrite: true,
+		MaxOpenConns:   8,
+		MaxIdleConns:   8,
+	}
+}
+
+// synchronous maps the synchronous settings to the values reported by SQLite.
+var synchronous = map[string]int{"OFF": 0, "NORMAL": 1, "FULL": 2, "EXTRA": 3}
+
+// Open returns a new SQLite database configured with opts, or an error if the
+// options are invalid, the database cannot be opened or any of the configured
+// pragmas did not take effect.
+func Open(ctx context.Context, opts Options) (*database.Database, error) {
+	if opts.Path == "" {
+		return nil, errors.New("sqlitedb.Open: path required")
+	}
+	if opts.Synchronous != "" {
+		if _, ok := synchronous[strings.ToUpper(opts.Synchronous)]; !ok {
+			return nil, fmt.Errorf("sqlitedb.Open: invalid synchronous setting %q", opts.Synchronous)
+		}
+	}
+
+	// functions are registered before the pools open any connections, as
+	// connections that are already open do not see them.
+	functions := opts.Functions
+	if opts.Builtins {
+		functions = append(slices.Clip(Builtins), functions...)
+	}
+	if err := Register(functions...); err != nil {
+		return nil, fmt.Errorf("sqlitedb.Open: %w", err)
+	}
+
+	if err := mkdir(opts.Path); err != nil {
+		return nil, fmt.Errorf("sqlitedb.Open: %w", err)
+	}
+	memory := inMemory(opts.Path)
+
+	dsn := opts.dsn()
+
+	var db *database.Database
+	var err error
+	if opts.SplitReadWrite && !memory {
+		db, err = database.OpenSplit(ctx, database.SQLITE, "sqlite", dsn, withPragma(dsn, "query_only(true)"))
+	} else {
+		db, err = database.Open(ctx, database.SQLITE, "sqlite", dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pool := db.Writer()
+	switch {
+	case memory:
+		// each connection to a private in memory database sees a different
+		// database, and a shared one is dropped when its last connection closes.
+		pool.SetMaxOpenConns(1)
+	case opts.SplitReadWrite:
+		pool.SetMaxOpenConns(1)
+		pool = db.Reader()
+		fallthrough
+	default:
+		if opts.MaxOpenConns > 0 {
+			pool.SetMaxOpenConns(opts.MaxOpenConns)
+		}
+		if opts.MaxIdleConns > 0 {
+			pool.SetMaxIdleConns(opts.MaxIdleConns)
+		}
+	}
+
+	for _, pool := range []*sqlx.DB{db.Writer(), db.Reader()} {
+		if err := opts.validate(ctx, pool); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// dsn returns the data source name for the options. The busy timeout is set
+// first so that the remaining pragmas wait for a locked database.
+func (o Options) dsn() string {
+	var pragmas []string
+	if o.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", o.BusyTimeout.Milliseconds()))
+	}
+	if o.JournalMode != "" {
+		pragmas = append(pragmas, fmt.Sprintf("journal_mode(%s)", o.JournalMode))
+	}
+	if o.Synchronous != "" {
+		pragmas = append(pragmas, fmt.Sprintf("synchronous(%s)", o.Synchronous))
+	}
+	if o.ForeignKeys {
+		pragmas = append(pragmas, "foreign_keys(1)")
+	}
+	if o.CacheSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(%d)", o.CacheSize))
+	}
+	if o.MmapSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("mmap_size(%d)", o.MmapSize))
+	}
+	pragmas = append(pragmas, o.Pragmas...)
+
+	dsn := o.Path
+	for _, pragma := range pragmas {
+		dsn = withPragma(dsn, pragma)
+	}
+	return dsn
+}
+
+// validate checks that the configured pragmas took effect on a connection from pool.
+func (o Options) validate(ctx context.Context, pool *sqlx.DB) error {
+	conn, err := pool.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("sqlitedb.Open: %w", err)
+	}
+	defer conn.Close()
+
+	check := func(pragma, expected string) error {
+		var got string
+		if err := conn.GetContext(ctx, &got, "PRAGMA "+pragma); err != nil {
+			return fmt.Errorf("sqlitedb.Open: read %s: %w", pragma, err)
+		}
+		if !strings.EqualFold(got, expected) {
+			return fmt.Errorf("sqlitedb.Open: %s is %s, expected %s", pragma, got, expected)
+		}
+		return nil
+	}
+
+	var checks [][2]string
+	if o.BusyTimeout > 0 {
+		checks = append(checks, [2]string{"busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10)})
+	}
+	if o.JournalMode != "" {
+		checks = append(checks, [2]string{"journal_mode", o.JournalMode})
+	}
+	if o.Synchronous != "" {
+		checks = append(checks, [2]string{"synchronous", strconv.Itoa(synchronous[strings.ToUpper(o.Synchronous)])})
+	}
+	if o.ForeignKeys {
+		checks = append(checks, [2]string{"foreign_keys", "1"})
+	}
+	if o.CacheSize != 0 {
+		checks = append(checks, [2]string{"cache_size", strconv.Itoa(o.CacheSize)})
+	}
+	if o.MmapSize != 0 {
+		checks = append(checks, [2]string{"mmap_size", strconv.FormatInt(o.MmapSize, 10)})
+	}
+
+	for _, c := range checks {
+		if err := check(c[0], c[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withPragma returns dsn with the pragma added as a _pragma parameter.
+func withPragma(dsn, pragma string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=" + url.QueryEscape(pragma)
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxTxAttempts specifies how many times WithTx runs a transaction that fails
+// because the database is busy or locked.
+const maxTxAttempts = 5
+
+// txBackoff specifies the initial delay between transaction attempts, which is
+// doubled after each attempt.
+const txBackoff = 10 * time.Millisecond
+
+// txKey is the context key a transaction is stored under.
+type txKey struct{}
+
+// Tx provides an sqlx embedded transaction that supports nested transactions
+// as savepoints.
+type Tx struct {
+	*sqlx.Tx
+	dbtype   dbtype
+	depth    int
+	readOnly bool
+}
+
+// NewTx begins a transaction with the given options, which may be nil.
+// Read-only transactions use the reader pool and are enforced on SQLite, whose
+// driver ignores the option, with PRAGMA query_only until the transaction ends,
+// unless the connection is already query only.
+func (d *Database) NewTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	pool := d.Writer()
+	if opts != nil && opts.ReadOnly {
+		pool = d.Reader()
+	}
+
+	sqlTx, err := pool.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{Tx: sqlTx, dbtype: d.dbtype}
+
+	if opts != nil && opts.ReadOnly && d.dbtype == SQLITE {
+		var queryOnly bool
+		if err := sqlTx.GetContext(ctx, &queryOnly, "PRAGMA query_only"); err != nil {
+			sqlTx.Rollback()
+			return nil, err
+		}
+
+		if !queryOnly {
+			if _, err := sqlTx.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+				sqlTx.Rollback()
+				return nil, err
+			}
+			tx.readOnly = true
+		}
+	}
+
+	return tx, nil
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	if err := tx.reset(); err != nil {
+		tx.Tx.Rollback()
+		return err
+	}
+	return tx.Tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (tx *Tx) Rollback() error {
+	if err := tx.reset(); err != nil {
+		return errors.Join(err, tx.Tx.Rollback())
+	}
+	return tx.Tx.Rollback()
+}
+
+// reset restores the connection settings changed for the transaction before
+// the connection is returned to the pool. Settings that were already in place,
+// such as query_only on a read-only pool, are left unchanged.
+func (tx *Tx) reset() error {
+	if !tx.readOnly {
+		return nil
+	}
+
+	_, err := tx.Exec("PRAGMA query_only = OFF")
+	return err
+}
+
+// ContextWithTx returns a copy of ctx carrying tx, so that WithTx calls made
+// with the returned context are nested within tx.
+func ContextWithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	return tx, ok
+}
+
+// WithTx runs fn in a transaction, committing it if fn returns nil and rolling
+// it back if fn returns an error or panics. If ctx carries a transaction, fn
+// runs in a savepoint within it instead.
+//
+// Transactions that fail because the database is busy or locked are retried
+// with backoff, so fn may be called more than once and must not have side
+// effects outside the transaction.
+func (d *Database) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithTx(ctx, fn)
+	}
+
+	backoff := txBackoff
+	for attempt := 1; ; attempt++ {
+		err := d.runTx(ctx, fn)
+		if err == nil || attempt == maxTxAttempts || !IsBusy(err) {
+			return err
+		}
+
+		delay := backoff/2 + rand.N(backoff)
+		backoff *= 2
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runTx runs fn in a single transaction attempt.
+func (d *Database) runTx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	tx, err := d.NewTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WithTx runs fn in a savepoint within the transaction, releasing it if fn
+// returns nil and rolling back to it if fn returns an error or panics. Busy
+// and locked errors are returned for the outermost WithTx to retry.
+func (tx *Tx) WithTx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	name := fmt.Sprintf("sittella_sp_%d", tx.depth+1)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	nested := &Tx{Tx: tx.Tx, dbtype: tx.dbtype, depth: tx.depth + 1, readOnly: tx.readOnly}
+
+	rollback := func() error {
+		_, err := tx.ExecContext(ctx, "ROLLBACK TO "+name+"; RELEASE "+name)
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE "+name)
+	return err
+}
+
+// IsBusy returns true if err is caused by the database being busy or locked,
+// such as SQLITE_BUSY or SQLITE_LOCKED, in which case the operation may be retried.
+func IsBusy(err error) bool {
+	var coder interface{ Code() int }
+	if !errors.As(err, &coder) {
+		return false
+	}
+
+	// extended result codes hold the primary result code in the lowest byte.
+	switch coder.Code() & 0xff {
+	case 5, 6: // SQLITE_BUSY, SQLITE_LOCKED
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,184 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+)
+
+func newDB(t *testing.T) (*database.Database, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+
+	db := sqlitedb.New(path)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE items (name TEXT NOT NULL)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return db, path
+}
+
+func count(t *testing.T, db *database.Database) int {
+	t.Helper()
+
+	var n int
+	if err := db.Get(&n, "SELECT COUNT(*) FROM items"); err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+
+	return n
+}
+
+func insert(name string) func(tx *database.Tx) error {
+	return func(tx *database.Tx) error {
+		_, err := tx.Exec("INSERT INTO items (name) VALUES (?)", name)
+		return err
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	db, _ := newDB(t)
+	errTest := errors.New("test error")
+
+	t.Run("commit", func(t *testing.T) {
+		if err := db.WithTx(t.Context(), insert("a")); err != nil {
+			t.Fatalf("failed to run transaction: %v", err)
+		}
+
+		if n := count(t, db); n != 1 {
+			t.Fatalf("expected 1 item, got %d", n)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		err := db.WithTx(t.Context(), func(tx *database.Tx) error {
+			if err := insert("b")(tx); err != nil {
+				return err
+			}
+			return errTest
+		})
+		if err != errTest {
+			t.Fatalf("expected %v got %v", errTest, err)
+		}
+
+		if n := count(t, db); n != 1 {
+			t.Fatalf("expected 1 item, got %d", n)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic to propagate")
+				}
+			}()
+
+			db.WithTx(t.Context(), func(tx *database.Tx) error {
+				insert("c")(tx)
+				panic("test panic")
+			})
+		}()
+
+		if n := count(t, db); n != 1 {
+			t.Fatalf("expected 1 item, got %d", n)
+		}
+	})
+
+	t.Run("savepoints", func(t *testing.T) {
+		err := db.WithTx(t.Context(), func(tx *database.Tx) error {
+			if err := insert("d")(tx); err != nil {
+				return err
+			}
+
+			if err := tx.WithTx(t.Context(), func(tx *database.Tx) error {
+				if err := insert("e")(tx); err != nil {
+					return err
+				}
+				return errTest
+			}); err != errTest {
+				t.Fatalf("expected %v got %v", errTest, err)
+			}
+
+			ctx := database.ContextWithTx(t.Context(), tx)
+			return db.WithTx(ctx, func(tx *database.Tx) error {
+				return tx.WithTx(ctx, insert("f"))
+			})
+		})
+		if err != nil {
+			t.Fatalf("failed to run transaction: %v", err)
+		}
+
+		var names []string
+		if err := db.Select(&names, "SELECT name FROM items ORDER BY name"); err != nil {
+			t.Fatalf("failed to select items: %v", err)
+		}
+
+		if len(names) != 3 || names[0] != "a" || names[1] != "d" || names[2] != "f" {
+			t.Fatalf("expected [a d f] got %v", names)
+		}
+	})
+}
+
+func TestWithTxBusy(t *testing.T) {
+	db, path := newDB(t)
+
+	other := sqlitedb.New(path)
+	defer other.Close()
+
+	conn, err := other.Conn(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(t.Context(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("failed to lock database: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO items (name) VALUES ('locked')")
+	if !database.IsBusy(err) {
+		t.Fatalf("expected busy error, got %v", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { conn.ExecContext(t.Context(), "COMMIT") })
+
+	if err := db.WithTx(t.Context(), insert("a")); err != nil {
+		t.Fatalf("expected transaction to be retried, got %v", err)
+	}
+
+	if n := count(t, db); n != 1 {
+		t.Fatalf("expected 1 item, got %d", n)
+	}
+}
+
+func TestNewTxReadOnly(t *testing.T) {
+	db, _ := newDB(t)
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.NewTx(t.Context(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO items (name) VALUES ('a')"); err == nil {
+		t.Fatal("expected write to fail in read only transaction")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	// the connection must be writable once returned to the pool.
+	if err := db.WithTx(t.Context(), insert("a")); err != nil {
+		t.Fatalf("failed to write after read only transaction: %v", err)
+	}
+}
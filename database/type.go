@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
@@ -12,32 +15,167 @@ const (
 	SQLITE dbtype = "sqlite"
 )
 
-var dbtypes = map[dbtype]struct{}{
-	SQLITE: {},
+// dbtypes maps the supported database types to their sqlx bind (placeholder) types.
+var dbtypes = map[dbtype]int{
+	SQLITE: sqlx.QUESTION,
 }
 
 // Database provides an sqlx embedded database.
+//
+// A Database may have a separate reader pool, in which case the query methods
+// (Query, QueryRow, Get, Select and their variants) use the reader pool and all
+// other methods, including Exec and transactions, use the embedded writer pool.
+// Statements that write and return rows, such as those with a RETURNING clause,
+// must be run on Writer.
 type Database struct {
 	*sqlx.DB
+	reader *sqlx.DB
 	dbtype dbtype
 }
 
-// New returns a new Database.
+// New returns a new Database, panicking if it cannot be opened.
 func New(dbtype dbtype, driver, dsn string) *Database {
+	db, err := Open(context.Background(), dbtype, driver, dsn)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// NewSplit returns a new Database with separate writer and reader pools,
+// connected with writerDSN and readerDSN respectively, panicking if it cannot be
+// opened.
+func NewSplit(dbtype dbtype, driver, writerDSN, readerDSN string) *Database {
+	db, err := OpenSplit(context.Background(), dbtype, driver, writerDSN, readerDSN)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// Open returns a new Database connected with dsn, or an error if the
+// arguments are invalid or the database cannot be reached.
+func Open(ctx context.Context, dbtype dbtype, driver, dsn string) (*Database, error) {
 	if _, ok := dbtypes[dbtype]; !ok {
-		panic(fmt.Sprintf("database.New: %s is not a supported dbtype", dbtype))
+		return nil, fmt.Errorf("database.Open: %s is not a supported dbtype", dbtype)
 	}
 	if driver == "" {
-		panic("database.New: driver required")
+		return nil, errors.New("database.Open: driver required")
 	}
 	if dsn == "" {
-		panic("database.New: dsn required")
+		return nil, errors.New("database.Open: dsn required")
 	}
 
-	db := sqlx.MustConnect(driver, dsn)
+	db, err := sqlx.ConnectContext(ctx, driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("database.Open: %w", err)
+	}
 
-	return &Database{DB: db, dbtype: dbtype}
+	return &Database{DB: db, dbtype: dbtype}, nil
+}
+
+// OpenSplit returns a new Database with separate writer and reader pools,
+// connected with writerDSN and readerDSN respectively, or an error if the
+// arguments are invalid or the database cannot be reached.
+func OpenSplit(ctx context.Context, dbtype dbtype, driver, writerDSN, readerDSN string) (*Database, error) {
+	if readerDSN == "" {
+		return nil, errors.New("database.OpenSplit: reader dsn required")
+	}
+
+	db, err := Open(ctx, dbtype, driver, writerDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	db.reader, err = sqlx.ConnectContext(ctx, driver, readerDSN)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database.OpenSplit: %w", err)
+	}
+
+	return db, nil
 }
 
 // Type returns the database type (i.e., SQLITE, POSTGRES, etc).
 func (d *Database) Type() dbtype { return d.dbtype }
+
+// Writer returns the pool used for writes and transactions.
+func (d *Database) Writer() *sqlx.DB { return d.DB }
+
+// Reader returns the pool used for queries, which is the writer pool if the
+// Database has no separate reader pool.
+func (d *Database) Reader() *sqlx.DB {
+	if d.reader != nil {
+		return d.reader
+	}
+	return d.DB
+}
+
+// Close closes the writer and reader pools.
+func (d *Database) Close() error {
+	err := d.DB.Close()
+	if d.reader != nil {
+		err = errors.Join(err, d.reader.Close())
+	}
+	return err
+}
+
+// Query executes a query that returns rows on the reader pool.
+func (d *Database) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.Reader().Query(query, args...)
+}
+
+// QueryContext executes a query that returns rows on the reader pool.
+func (d *Database) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.Reader().QueryContext(ctx, query, args...)
+}
+
+// QueryRow executes a query that returns at most one row on the reader pool.
+func (d *Database) QueryRow(query string, args ...any) *sql.Row {
+	return d.Reader().QueryRow(query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row on the reader pool.
+func (d *Database) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.Reader().QueryRowContext(ctx, query, args...)
+}
+
+// Queryx executes a query that returns sqlx.Rows on the reader pool.
+func (d *Database) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	return d.Reader().Queryx(query, args...)
+}
+
+// QueryxContext executes a query that returns sqlx.Rows on the reader pool.
+func (d *Database) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return d.Reader().QueryxContext(ctx, query, args...)
+}
+
+// QueryRowx executes a query that returns an sqlx.Row on the reader pool.
+func (d *Database) QueryRowx(query string, args ...any) *sqlx.Row {
+	return d.Reader().QueryRowx(query, args...)
+}
+
+// QueryRowxContext executes a query that returns an sqlx.Row on the reader pool.
+func (d *Database) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	return d.Reader().QueryRowxContext(ctx, query, args...)
+}
+
+// Get scans a single row into dest on the reader pool.
+func (d *Database) Get(dest any, query string, args ...any) error {
+	return d.Reader().Get(dest, query, args...)
+}
+
+// GetContext scans a single row into dest on the reader pool.
+func (d *Database) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return d.Reader().GetContext(ctx, dest, query, args...)
+}
+
+// Select scans all rows into dest on the reader pool.
+func (d *Database) Select(dest any, query string, args ...any) error {
+	return d.Reader().Select(dest, query, args...)
+}
+
+// SelectContext scans all rows into dest on the reader pool.
+func (d *Database) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return d.Reader().SelectContext(ctx, dest, query, args...)
+}
@@ -0,0 +1,34 @@
+package sittella
+
+import (
+	stdcontext "context"
+	"io"
+
+	"github.com/a-h/templ"
+	"github.com/dimmerz92/sittella/sessions"
+)
+
+// FlashMessages renders the given flash messages as alert elements with the
+// classes "flash" and "flash-{kind}", or nothing if there are none.
+func FlashMessages(flashes []sessions.Flash) templ.Component {
+	return templ.ComponentFunc(func(ctx stdcontext.Context, w io.Writer) error {
+		if len(flashes) == 0 {
+			return nil
+		}
+
+		if _, err := io.WriteString(w, `<div class="flashes">`); err != nil {
+			return err
+		}
+
+		for _, flash := range flashes {
+			_, err := io.WriteString(w, `<div class="flash flash-`+templ.EscapeString(flash.Kind)+`" role="alert">`+
+				templ.EscapeString(flash.Message)+`</div>`)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := io.WriteString(w, `</div>`)
+		return err
+	})
+}
@@ -0,0 +1,108 @@
+package sittella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/dimmerz92/sittella/sessions"
+)
+
+func TestFlashMessages(t *testing.T) {
+	var html strings.Builder
+	err := FlashMessages([]sessions.Flash{
+		{Kind: "success", Message: "saved"},
+		{Kind: `x"`, Message: "<b>bad</b>"},
+	}).Render(t.Context(), &html)
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+
+	expected := `<div class="flashes">` +
+		`<div class="flash flash-success" role="alert">saved</div>` +
+		`<div class="flash flash-x&#34;" role="alert">&lt;b&gt;bad&lt;/b&gt;</div>` +
+		`</div>`
+	if html.String() != expected {
+		t.Fatalf("expected %s got %s", expected, html.String())
+	}
+
+	html.Reset()
+	if err := FlashMessages(nil).Render(t.Context(), &html); err != nil || html.Len() != 0 {
+		t.Fatalf("expected nothing rendered without flashes, got %q: %v", html.String(), err)
+	}
+}
+
+func TestFlashRedirect(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+
+	a.POST("/save", func(c core.Context) error {
+		if err := c.Flash("success", "saved"); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, "/page")
+	})
+	a.GET("/page", func(c core.Context) error {
+		flashes, err := c.Flashes()
+		if err != nil {
+			return err
+		}
+		return c.Render(http.StatusOK, FlashMessages(flashes))
+	})
+	a.GET("/other", func(c core.Context) error {
+		var name string
+		c.Session().Get("name", &name)
+		return c.NoContent(http.StatusOK)
+	})
+	a.GET("/static", func(c core.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	save := func(htmx bool) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest("POST", "/save", nil)
+		if htmx {
+			r.Header.Set("Hx-Request", "true")
+		}
+
+		w := do(a, r)
+		if htmx && w.Header().Get("Hx-Redirect") != "/page" {
+			t.Fatalf("expected htmx redirect got %v", w.Header())
+		}
+		if !htmx && w.Header().Get("Location") != "/page" {
+			t.Fatalf("expected redirect got %v", w.Header())
+		}
+
+		return w
+	}
+
+	page := func(w *httptest.ResponseRecorder) string {
+		return do(a, withCookies("/page", w)).Body.String()
+	}
+
+	for name, htmx := range map[string]bool{"redirect": false, "htmx": true} {
+		t.Run(name, func(t *testing.T) {
+			w := save(htmx)
+
+			if got := page(w); !strings.Contains(got, "saved") {
+				t.Fatalf("expected flash after redirect, got %q", got)
+			}
+			if got := page(w); got != "" {
+				t.Fatalf("expected flash to be consumed, got %q", got)
+			}
+		})
+	}
+
+	for _, path := range []string{"/other", "/static"} {
+		t.Run("expired "+path, func(t *testing.T) {
+			w := save(false)
+			do(a, withCookies(path, w))
+
+			if got := page(w); got != "" {
+				t.Fatalf("expected flash to expire after the next request, got %q", got)
+			}
+		})
+	}
+}
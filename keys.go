@@ -0,0 +1,34 @@
+package sittella
+
+import "github.com/dimmerz92/sittella/core"
+
+// Key is a typed key for values held in the request scoped context store.
+// The value type is fixed by the key, so mismatched reads and writes are caught
+// at compile time rather than by runtime type assertions.
+// Values are still held in the store as any, so typed keys remove the type
+// assertions at call sites but not the boxing of the values themselves.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new typed context store key with the given name.
+func NewKey[T any](name string) Key[T] { return Key[T]{name: name} }
+
+// Name returns the name the key is stored under.
+func (k Key[T]) Name() string { return k.name }
+
+// Value returns the value mapped to by the given key from the context store if
+// it exists and is of the key's type.
+func Value[T any](c core.Context, key Key[T]) (T, bool) {
+	value, ok := c.Get(key.name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	typed, ok := value.(T)
+	return typed, ok
+}
+
+// SetValue adds the key value pair to the context store.
+func SetValue[T any](c core.Context, key Key[T], value T) { c.Set(key.name, value) }
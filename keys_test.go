@@ -0,0 +1,37 @@
+package sittella
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+	c := a.newContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	count := NewKey[int]("count")
+	if count.Name() != "count" {
+		t.Fatalf("expected name count got %q", count.Name())
+	}
+
+	if value, ok := Value(c, count); ok || value != 0 {
+		t.Fatalf("expected missing value, got %d %v", value, ok)
+	}
+
+	SetValue(c, count, 42)
+	if value, ok := Value(c, count); !ok || value != 42 {
+		t.Fatalf("expected 42 got %d %v", value, ok)
+	}
+
+	// values stored under the same name with another type are not returned.
+	c.Set("count", "42")
+	if value, ok := Value(c, count); ok || value != 0 {
+		t.Fatalf("expected mismatched value to be ignored, got %d %v", value, ok)
+	}
+
+	// keys share the context store with the untyped accessors.
+	SetValue(c, NewKey[string]("name"), "sittella")
+	if value, ok := c.Get("name"); !ok || value != "sittella" {
+		t.Fatalf("expected sittella got %v %v", value, ok)
+	}
+}
@@ -0,0 +1,70 @@
+package sittella
+
+import (
+	"time"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to propagate request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the length of request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+var (
+	// RequestIDKey maps to the current request ID in the context store.
+	RequestIDKey = NewKey[string]("sittella.request_id")
+
+	// UserIDKey maps to the authenticated user ID in the context store.
+	// Set it from authentication middleware to include it in request logs.
+	UserIDKey = NewKey[string]("sittella.user_id")
+)
+
+// RequestLogger returns a middleware that assigns or propagates the request ID
+// and logs a single record for each request once it has been handled.
+func RequestLogger() core.MiddlewareFunc {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(c core.Context) error {
+			start := time.Now()
+
+			id := c.Request().Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			SetValue(c, RequestIDKey, id)
+			c.Response().Header().Set(RequestIDHeader, id)
+
+			err := next(c)
+
+			attrs := []any{
+				"method", c.Request().Method,
+				"path", c.Request().URL.Path,
+				"status", c.Response().Status(),
+				"bytes", c.Response().Size(),
+				"duration", time.Since(start),
+			}
+			if err != nil {
+				c.Logger().Error("request", append(attrs, "error", err)...)
+			} else {
+				c.Logger().Info("request", attrs...)
+			}
+
+			return err
+		}
+	}
+}
+
+// validRequestID reports whether a client supplied request ID is safe to reuse.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
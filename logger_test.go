@@ -0,0 +1,140 @@
+package sittella
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/google/uuid"
+)
+
+// records decodes the JSON log records written to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	buf.Reset()
+
+	return records
+}
+
+func TestRequestID(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+	a.Use(RequestLogger())
+
+	var seen string
+	a.GET("/", func(c core.Context) error {
+		seen, _ = Value(c, RequestIDKey)
+		return nil
+	})
+
+	for name, test := range map[string]struct {
+		header string
+		reused bool
+	}{
+		"generated":  {header: ""},
+		"propagated": {header: "req-123", reused: true},
+		"invalid":    {header: "req 123"},
+		"too long":   {header: strings.Repeat("a", maxRequestIDLength+1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.header != "" {
+				r.Header.Set(RequestIDHeader, test.header)
+			}
+
+			w := do(a, r)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id != seen {
+				t.Fatalf("expected response header %q to match context value %q", id, seen)
+			}
+
+			if test.reused {
+				if id != test.header {
+					t.Fatalf("expected request id %q got %q", test.header, id)
+				}
+				return
+			}
+
+			if _, err := uuid.Parse(id); err != nil {
+				t.Fatalf("expected generated uuid got %q", id)
+			}
+		})
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	a := newTestApp(t, Config{}, &buf)
+	a.Use(RequestLogger())
+
+	a.GET("/items/{id}", func(c core.Context) error {
+		SetValue(c, UserIDKey, "user-1")
+		c.Logger().Info("handler")
+		return c.String(201, "hello")
+	})
+
+	r := httptest.NewRequest("GET", "/items/1", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	do(a, r)
+
+	logs := records(t, &buf)
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 log records got %d", len(logs))
+	}
+
+	handler, request := logs[0], logs[1]
+	for key, expected := range map[string]any{
+		"msg":        "handler",
+		"request_id": "req-1",
+		"route":      "/items/{id}",
+		"user_id":    "user-1",
+	} {
+		if handler[key] != expected {
+			t.Fatalf("expected handler record %s %v got %v", key, expected, handler[key])
+		}
+	}
+
+	for key, expected := range map[string]any{
+		"msg":        "request",
+		"level":      "INFO",
+		"method":     "GET",
+		"path":       "/items/1",
+		"status":     float64(201),
+		"bytes":      float64(5),
+		"request_id": "req-1",
+	} {
+		if request[key] != expected {
+			t.Fatalf("expected request record %s %v got %v", key, expected, request[key])
+		}
+	}
+	if _, ok := request["duration"]; !ok {
+		t.Fatal("expected request record to include duration")
+	}
+
+	// route middleware sees the handler error.
+	a = newTestApp(t, Config{}, &buf)
+	a.OnHandlerError(func(c core.Context, err error) {})
+
+	errTest := errors.New("test error")
+	a.GET("/fail", func(c core.Context) error { return errTest }, RequestLogger())
+
+	do(a, httptest.NewRequest("GET", "/fail", nil))
+
+	logs = records(t, &buf)
+	if len(logs) != 1 || logs[0]["level"] != "ERROR" || logs[0]["error"] != errTest.Error() {
+		t.Fatalf("expected error record got %v", logs)
+	}
+}
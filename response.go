@@ -0,0 +1,159 @@
+package sittella
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps a http.ResponseWriter to record the response state and
+// run callbacks before the headers are sent.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	written bool
+	before  []func()
+
+	// held, header and body buffer the response while it is held; see hold.
+	held   bool
+	header http.Header
+	body   bytes.Buffer
+}
+
+// Before registers a callback to run immediately before the response
+// headers are sent. Callbacks run in the order they were registered, and Status
+// returns the status being sent while they run.
+func (w *responseWriter) Before(callback func()) {
+	w.before = append(w.before, callback)
+}
+
+// WriteHeader runs any before callbacks and sends the response headers with the
+// given status. Informational statuses are passed through and subsequent calls
+// are ignored.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.written = true
+	w.status = status
+
+	if !w.held {
+		w.send()
+	}
+}
+
+// send runs any before callbacks and sends the response headers.
+func (w *responseWriter) send() {
+	for _, callback := range w.before {
+		callback()
+	}
+	w.before = nil
+
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Write sends the headers with status 200 - OK if they have not been sent and
+// writes the given bytes to the response body.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.held {
+		n, err := w.body.Write(b)
+		w.size += n
+		return n, err
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Status returns the response status, or 200 - OK if none has been written.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written returns true if the response headers have been sent.
+func (w *responseWriter) Written() bool { return w.written }
+
+// Size returns the number of body bytes written to the response.
+func (w *responseWriter) Size() int { return w.size }
+
+// Flush sends the headers if they have not been sent and flushes any buffered
+// data to the client. It does nothing while the response is held.
+func (w *responseWriter) Flush() {
+	if w.held {
+		return
+	}
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// hold buffers the response until release is called, so the before callbacks
+// run and the headers are sent only once the response is complete. Returns
+// false if the response is already held or the headers have been sent.
+func (w *responseWriter) hold() bool {
+	if w.held || w.written {
+		return false
+	}
+	w.held = true
+	w.header = w.Header().Clone()
+	return true
+}
+
+// discard drops the held response, restoring the headers set before it was
+// held, so that a different response can be written.
+func (w *responseWriter) discard() {
+	if !w.held {
+		return
+	}
+	clear(w.Header())
+	for key, values := range w.header {
+		w.Header()[key] = values
+	}
+	w.status = 0
+	w.size = 0
+	w.written = false
+	w.body.Reset()
+}
+
+// release stops holding the response, sending it if it has been written.
+func (w *responseWriter) release() {
+	if !w.held {
+		return
+	}
+	w.held = false
+	w.header = nil
+
+	if !w.written {
+		return
+	}
+	w.send()
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+}
+
+// Hijack lets the caller take over the underlying connection.
+// Before callbacks are not run for hijacked connections.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.written = true
+		w.before = nil
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying response writer for use by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,144 @@
+package sittella
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/core"
+)
+
+func TestResponseWriter(t *testing.T) {
+	t.Run("before hooks", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w := &responseWriter{ResponseWriter: rec}
+
+		var order []string
+		var statuses []int
+		for _, name := range []string{"a", "b"} {
+			w.Before(func() {
+				order = append(order, name)
+				statuses = append(statuses, w.Status())
+				w.Header().Add("X-Hook", name)
+			})
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusTeapot)
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+
+		if !slices.Equal(order, []string{"a", "b"}) {
+			t.Fatalf("expected hooks to run once in order, got %v", order)
+		}
+		if !slices.Equal(statuses, []int{http.StatusCreated, http.StatusCreated}) {
+			t.Fatalf("expected hooks to see status 201, got %v", statuses)
+		}
+		if got := rec.Header().Values("X-Hook"); !slices.Equal(got, []string{"a", "b"}) {
+			t.Fatalf("expected headers set by hooks to be sent, got %v", got)
+		}
+		if rec.Code != http.StatusCreated || w.Status() != http.StatusCreated {
+			t.Fatalf("expected status 201 got %d (%d)", rec.Code, w.Status())
+		}
+		if !w.Written() || w.Size() != 5 {
+			t.Fatalf("expected written 5 bytes, got %v %d", w.Written(), w.Size())
+		}
+	})
+
+	t.Run("write and flush", func(t *testing.T) {
+		for name, send := range map[string]func(w *responseWriter){
+			"write": func(w *responseWriter) { w.Write([]byte("x")) },
+			"flush": func(w *responseWriter) { w.Flush() },
+			"controller": func(w *responseWriter) {
+				http.NewResponseController(w).Flush()
+			},
+		} {
+			rec := httptest.NewRecorder()
+			w := &responseWriter{ResponseWriter: rec}
+
+			var status int
+			w.Before(func() { status = w.Status() })
+
+			if w.Status() != http.StatusOK || w.Written() {
+				t.Fatalf("%s: expected unwritten response with status 200", name)
+			}
+
+			send(w)
+
+			if status != http.StatusOK || !w.Written() {
+				t.Fatalf("%s: expected hooks to run with status 200", name)
+			}
+			if name != "write" && !rec.Flushed {
+				t.Fatalf("%s: expected response to be flushed", name)
+			}
+		}
+	})
+
+	t.Run("hijack", func(t *testing.T) {
+		var ran bool
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			w := &responseWriter{ResponseWriter: rw}
+			w.Before(func() { ran = true })
+
+			conn, buf, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack: %v", err)
+				return
+			}
+			defer conn.Close()
+
+			w.WriteHeader(http.StatusOK)
+			buf.WriteString("HTTP/1.1 204 No Content\r\n\r\n")
+			buf.Flush()
+		}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("failed to request: %v", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent || ran {
+			t.Fatalf("expected hijacked response without hooks, got %d %v", res.StatusCode, ran)
+		}
+	})
+}
+
+func TestResponseBeforeOrder(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+
+	var order []string
+	a.Use(func(next core.HandlerFunc) core.HandlerFunc {
+		return func(c core.Context) error {
+			c.Response().Before(func() { order = append(order, "middleware") })
+			err := next(c)
+			order = append(order, "after")
+			return err
+		}
+	})
+
+	a.GET("/", func(c core.Context) error {
+		c.Response().Before(func() { order = append(order, "handler") })
+		// the session is saved by a hook registered when the request starts.
+		if err := c.Session().Set("key", time.Now().String()); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusAccepted)
+	})
+
+	w := do(a, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 got %d", w.Code)
+	}
+	if !slices.Equal(order, []string{"middleware", "handler", "after"}) {
+		t.Fatalf("unexpected order %v", order)
+	}
+	if len(w.Result().Cookies()) != 1 {
+		t.Fatal("expected session cookie to be set by the first hook")
+	}
+}
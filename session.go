@@ -0,0 +1,18 @@
+package sittella
+
+import "github.com/dimmerz92/sittella/core"
+
+// readOnlySessionKey marks requests whose session must not be saved.
+var readOnlySessionKey = NewKey[bool]("sittella.session.read_only")
+
+// ReadOnlySession returns a middleware that disables automatically saving the
+// session for the routes it is applied to. Changes made to the session are
+// discarded unless Save is called explicitly.
+func ReadOnlySession() core.MiddlewareFunc {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(c core.Context) error {
+			SetValue(c, readOnlySessionKey, true)
+			return next(c)
+		}
+	}
+}
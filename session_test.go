@@ -0,0 +1,151 @@
+package sittella
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/dimmerz92/sittella/sessions"
+)
+
+// failingStore wraps a store so that saving its sessions fails.
+type failingStore struct {
+	sessions.Store
+}
+
+func (s failingStore) Session(w http.ResponseWriter, r *http.Request) sessions.Session {
+	return failingSession{s.Store.Session(w, r)}
+}
+
+type failingSession struct {
+	sessions.Session
+}
+
+var errSave = errors.New("save failed")
+
+func (failingSession) Save() error { return errSave }
+
+// withCookies returns a request for path carrying the cookies set by w.
+func withCookies(path string, w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest("GET", path, nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestSessionAutoSave(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+
+	a.GET("/set", func(c core.Context) error {
+		if err := c.Session().Set("name", "sittella"); err != nil {
+			return err
+		}
+		// the session is saved before the body is written.
+		return c.String(http.StatusOK, "set")
+	})
+	a.GET("/set-silent", func(c core.Context) error {
+		return c.Session().Set("name", "silent")
+	})
+	a.GET("/set-read-only", func(c core.Context) error {
+		return c.Session().Set("name", "read only")
+	}, ReadOnlySession())
+	a.GET("/get", func(c core.Context) error {
+		var name string
+		if err := c.Session().Get("name", &name); err != nil {
+			return c.String(http.StatusOK, "")
+		}
+		return c.String(http.StatusOK, name)
+	})
+
+	for path, expected := range map[string]string{
+		"/set":           "sittella",
+		"/set-silent":    "silent",
+		"/set-read-only": "",
+	} {
+		w := do(a, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 got %d", path, w.Code)
+		}
+
+		if got := do(a, withCookies("/get", w)).Body.String(); got != expected {
+			t.Fatalf("%s: expected %q got %q", path, expected, got)
+		}
+	}
+}
+
+func TestSessionSaveError(t *testing.T) {
+	store := newTestApp(t, Config{}, nil).sessionStore
+	a := newTestApp(t, Config{SessionStore: failingStore{store}}, nil)
+
+	var handled error
+	a.OnHandlerError(func(c core.Context, err error) {
+		handled = err
+		c.NoContent(http.StatusInternalServerError)
+	})
+
+	for path, handler := range map[string]core.HandlerFunc{
+		"/silent": func(c core.Context) error {
+			return c.Session().Set("name", "sittella")
+		},
+		"/written": func(c core.Context) error {
+			c.Session().Set("name", "sittella")
+			return c.NoContent(http.StatusOK)
+		},
+	} {
+		handled = nil
+		a.GET(path, handler)
+
+		w := do(a, httptest.NewRequest("GET", path, nil))
+		if !errors.Is(handled, errSave) {
+			t.Fatalf("%s: expected error handler to receive %v got %v", path, errSave, handled)
+		}
+
+		// the status can only be changed if the headers were not yet sent.
+		if path == "/silent" && w.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: expected 500 got %d", path, w.Code)
+		}
+	}
+}
+
+// countingStore wraps a store to count the sessions loaded from it.
+type countingStore struct {
+	sessions.Store
+	loads *int
+}
+
+func (s countingStore) Session(w http.ResponseWriter, r *http.Request) sessions.Session {
+	*s.loads++
+	return s.Store.Session(w, r)
+}
+
+func TestSessionLazy(t *testing.T) {
+	var loads int
+	store := newTestApp(t, Config{}, nil).sessionStore
+	a := newTestApp(t, Config{SessionStore: countingStore{store, &loads}}, nil)
+
+	a.GET("/static", func(c core.Context) error {
+		return c.String(http.StatusOK, "static")
+	})
+	a.GET("/read", func(c core.Context) error {
+		var name string
+		c.Session().Get("name", &name)
+		c.Session().Get("other", &name)
+		return c.String(http.StatusOK, name)
+	})
+
+	w := do(a, httptest.NewRequest("GET", "/static", nil))
+	if loads != 0 || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("expected no session load or cookie, got %d loads and %v", loads, w.Result().Cookies())
+	}
+
+	w = do(a, httptest.NewRequest("GET", "/read", nil))
+	if loads != 1 {
+		t.Fatalf("expected the session to be loaded once, got %d", loads)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Fatalf("expected no cookie for an unmodified session, got %v", w.Result().Cookies())
+	}
+}
@@ -0,0 +1,118 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// payloadVersion is the current version of the payload header format.
+const payloadVersion = 1
+
+// ErrUnknownCodec is returned when a value was encoded with an unregistered codec.
+var ErrUnknownCodec = errors.New("unknown session codec")
+
+// Codec specifies an interface to encode and decode session values.
+type Codec interface {
+	// ID returns the unique identifier written to the header of encoded payloads.
+	// IDs 0 to 15 are reserved for codecs provided by this package.
+	ID() byte
+
+	// Encode encodes the given value.
+	Encode(value any) ([]byte, error)
+
+	// Decode decodes the data into the value pointed to by dest.
+	Decode(data []byte, dest any) error
+}
+
+var (
+	// GobCodec encodes values with encoding/gob. Interface values must be
+	// registered with gob.Register.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec encodes values with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+)
+
+var codecs = struct {
+	sync.RWMutex
+	m map[byte]Codec
+}{m: map[byte]Codec{
+	GobCodec.ID():  GobCodec,
+	JSONCodec.ID(): JSONCodec,
+}}
+
+// RegisterCodec registers a codec so that values encoded with it can be decoded.
+// Codecs must be registered before any values encoded with them are read.
+func RegisterCodec(codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if existing, ok := codecs.m[codec.ID()]; ok && existing != codec {
+		panic(fmt.Sprintf("sessions.RegisterCodec: codec id %d already registered", codec.ID()))
+	}
+	codecs.m[codec.ID()] = codec
+}
+
+// Encode encodes the value with the given codec and prefixes it with a header
+// identifying the payload version and codec.
+func Encode(codec Codec, value any) ([]byte, error) {
+	encoded, err := codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// gob streams never start with a zero byte, so the header cannot be
+	// mistaken for a legacy payload.
+	return append([]byte{0, payloadVersion, codec.ID()}, encoded...), nil
+}
+
+// Decode decodes the payload into dest using the codec identified by its header,
+// regardless of the codec currently used to encode new values. Payloads without
+// a header are decoded with GobCodec.
+func Decode(data []byte, dest any) error {
+	if len(data) == 0 || data[0] != 0 {
+		return GobCodec.Decode(data, dest)
+	}
+
+	if len(data) < 3 || data[1] != payloadVersion {
+		return fmt.Errorf("sessions.Decode: unsupported payload header")
+	}
+
+	codecs.RLock()
+	codec, ok := codecs.m[data[2]]
+	codecs.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownCodec, data[2])
+	}
+
+	return codec.Decode(data[3:], dest)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return 1 }
+
+func (gobCodec) Encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, dest any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(dest)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return 2 }
+
+func (jsonCodec) Encode(value any) ([]byte, error) { return json.Marshal(value) }
+
+func (jsonCodec) Decode(data []byte, dest any) error { return json.Unmarshal(data, dest) }
@@ -0,0 +1,58 @@
+package sessions_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dimmerz92/sittella/sessions"
+)
+
+type testType struct {
+	Data int
+}
+
+var expected = testType{42}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []sessions.Codec{sessions.GobCodec, sessions.JSONCodec} {
+		encoded, err := sessions.Encode(codec, expected)
+		if err != nil {
+			t.Fatalf("failed to encode with codec %d: %v", codec.ID(), err)
+		}
+
+		var out testType
+		if err := sessions.Decode(encoded, &out); err != nil {
+			t.Fatalf("failed to decode with codec %d: %v", codec.ID(), err)
+		}
+
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected %#v got %#v", expected, out)
+		}
+	}
+
+	t.Run("legacy", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(expected); err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+
+		var out testType
+		if err := sessions.Decode(buf.Bytes(), &out); err != nil {
+			t.Fatalf("failed to decode legacy payload: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected %#v got %#v", expected, out)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		var out testType
+		if err := sessions.Decode([]byte{0, 1, 255, '{', '}'}, &out); !errors.Is(err, sessions.ErrUnknownCodec) {
+			t.Fatalf("expected unknown codec, got %v", err)
+		}
+	})
+}
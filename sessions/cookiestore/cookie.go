@@ -0,0 +1,462 @@
+package cookiestore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/google/uuid"
+)
+
+// MaxCookieSize is the maximum size in bytes of a single Set-Cookie header
+// value that browsers are guaranteed to accept.
+const MaxCookieSize = 4096
+
+// ErrCookieTooLarge is returned when an encrypted session exceeds the size
+// that can be stored across the allowed number of cookies.
+var ErrCookieTooLarge = errors.New("session too large for cookie storage")
+
+// ErrInvalidCookie is returned when a session cookie cannot be decrypted.
+var ErrInvalidCookie = errors.New("invalid session cookie")
+
+type session struct {
+	ID       string
+	UserID   string
+	Created  time.Time
+	LastSeen time.Time
+	Idle     time.Duration
+	Data     map[string][]byte
+}
+
+// expiry returns the earlier of the idle and absolute expiry datetimes.
+func (s session) expiry(absolute time.Duration) time.Time {
+	expiry := s.LastSeen.Add(s.Idle)
+	if absolute > 0 && s.Created.Add(absolute).Before(expiry) {
+		return s.Created.Add(absolute)
+	}
+	return expiry
+}
+
+// Store defines a stateless session store that keeps each session in an
+// AES-GCM encrypted and authenticated cookie.
+// As the session lives on the client, overlapping requests for the same session
+// cannot be merged and the last response to set the cookie wins.
+//
+// For the same reason the store cannot revoke a session. Regenerate and Destroy
+// only replace or clear the cookies held by the client making the request; any
+// copy of the previous cookies, such as one planted before login or captured by
+// an attacker, stays valid until it expires. Use a short idle timeout and
+// absolute lifetime, or a server side store where sessions must be revocable.
+type Store struct {
+	cookie    sessions.CookieOpts
+	idle      time.Duration
+	maxChunks int
+	aeads     []cipher.AEAD
+	options   sessions.Options
+}
+
+// New returns a new encrypted cookie session Store.
+// Parameters:
+// - idle specifies how long a session lives without activity; it is renewed each time the session is saved.
+// - maxChunks specifies the maximum number of cookies a session may be split across; values below 2 disable chunking.
+// - cookie specifies the cookie options to be used.
+// - keys specify the 16, 24 or 32 byte AES keys; the first key encrypts and all keys decrypt, allowing keys to be rotated.
+// - opts specify optional store settings such as the value codec, absolute lifetime and lifecycle hooks.
+//
+// As sessions are only held by the client, the OnExpire hook is never called.
+func New(idle time.Duration, maxChunks int, cookie sessions.CookieOpts, keys [][]byte, opts ...sessions.Option) (*Store, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cookiestore.New: at least one key required")
+	}
+
+	store := &Store{
+		cookie:    cookie,
+		options:   sessions.NewOptions(opts...),
+		idle:      idle,
+		maxChunks: max(maxChunks, 1),
+	}
+
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("cookiestore.New: %w", err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("cookiestore.New: %w", err)
+		}
+
+		store.aeads = append(store.aeads, aead)
+	}
+
+	return store, nil
+}
+
+// Session retrieves the session for the current request, or generates a new
+// session if none exists. No cookie is set until data is saved to the session.
+// Existing sessions are renewed once enough of their idle timeout has passed, see
+// sessions.Renew.
+func (s *Store) Session(w http.ResponseWriter, r *http.Request) sessions.Session {
+	sess := &Session{
+		req:   r,
+		res:   w,
+		store: s,
+		mu:    &sync.RWMutex{},
+	}
+
+	sess.chunks = s.chunks(r)
+	if sess.chunks > 0 {
+		data, err := s.decode(r)
+		if err == nil && time.Now().Before(data.expiry(s.options.Absolute)) {
+			sess.data = data
+			sess.changed = sessions.Renew(data.LastSeen, data.Idle)
+			return sess
+		}
+	}
+
+	now := time.Now()
+	sess.data = session{
+		Created:  now,
+		LastSeen: now,
+		Idle:     s.idle,
+		Data:     make(map[string][]byte),
+	}
+
+	return sess
+}
+
+// HasSession returns true if the request carries a session cookie.
+func (s *Store) HasSession(r *http.Request) bool { return s.chunks(r) > 0 }
+
+// Stop releases any goroutines and resources allocated by the Store.
+func (s *Store) Stop() {}
+
+// chunkName returns the cookie name for the chunk at index i.
+func (s *Store) chunkName(i int) string {
+	if i == 0 {
+		return s.cookie.Name
+	}
+	return s.cookie.Name + "_" + strconv.Itoa(i)
+}
+
+// chunks returns the number of session cookies sent with the request.
+func (s *Store) chunks(r *http.Request) int {
+	var n int
+	for ; n < s.maxChunks; n++ {
+		if _, err := r.Cookie(s.chunkName(n)); err != nil {
+			break
+		}
+	}
+	return n
+}
+
+// decode joins, decrypts and decodes the session cookies sent with the request.
+func (s *Store) decode(r *http.Request) (session, error) {
+	var value string
+	for i := range s.chunks(r) {
+		cookie, _ := r.Cookie(s.chunkName(i))
+		value += cookie.Value
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return session{}, ErrInvalidCookie
+	}
+
+	for _, aead := range s.aeads {
+		if len(ciphertext) < aead.NonceSize() {
+			continue
+		}
+
+		nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, sealed, []byte(s.cookie.Name))
+		if err != nil {
+			continue
+		}
+
+		var data session
+		if err := gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&data); err != nil {
+			return session{}, err
+		}
+
+		return data, nil
+	}
+
+	return session{}, ErrInvalidCookie
+}
+
+// encode encodes and encrypts the session with the current key and splits it
+// into chunks that fit within MaxCookieSize.
+func (s *Store) encode(data session, ttl time.Duration) ([]*http.Cookie, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, buf.Bytes(), []byte(s.cookie.Name)))
+
+	overhead := len(s.cookie.ToCookie("", ttl).String()) + len(s.chunkName(s.maxChunks-1)) - len(s.cookie.Name)
+	size := MaxCookieSize - overhead
+	if len(value) > size*s.maxChunks {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d cookie(s) of %d bytes", ErrCookieTooLarge, len(value), s.maxChunks, size)
+	}
+
+	var cookies []*http.Cookie
+	for i := 0; len(value) > 0; i++ {
+		n := min(size, len(value))
+		cookie := s.cookie.ToCookie(value[:n], ttl)
+		cookie.Name = s.chunkName(i)
+		cookies = append(cookies, cookie)
+		value = value[n:]
+	}
+
+	return cookies, nil
+}
+
+// revoke expires the session cookies from index i up to n.
+func (s *Store) revoke(w http.ResponseWriter, i, n int) {
+	for ; i < n; i++ {
+		cookie := s.cookie.ToRevoked()
+		cookie.Name = s.chunkName(i)
+		http.SetCookie(w, cookie)
+	}
+}
+
+// Session defines an encrypted cookie request scoped session.
+type Session struct {
+	req     *http.Request
+	res     http.ResponseWriter
+	store   *Store
+	data    session
+	chunks  int
+	mu      *sync.RWMutex
+	changed bool
+}
+
+// Set adds or updates the key value pair to the session.
+func (s *Session) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoded, err := sessions.Encode(s.store.options.Codec, value)
+	if err != nil {
+		return err
+	}
+
+	s.data.Data[key] = encoded
+
+	s.changed = true
+
+	return nil
+}
+
+// Get retrieves and decodes the value mapped to by the given key if it exists.
+func (s *Session) Get(key string, dest any) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data.Data[key]
+	if !ok {
+		return sessions.ErrValueNotFound
+	}
+
+	return sessions.Decode(data, dest)
+}
+
+// Delete removes the value mapped to by the given key from the session.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Data, key)
+
+	s.changed = true
+}
+
+// Clear removes all key value pairs from the session.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Data = make(map[string][]byte)
+
+	s.changed = true
+}
+
+// Codec returns the codec used to encode session values.
+func (s *Session) Codec() sessions.Codec { return s.store.options.Codec }
+
+// CreatedAt returns the datetime the session was created.
+func (s *Session) CreatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.Created
+}
+
+// LastSeen returns the datetime the session was last saved.
+func (s *Session) LastSeen() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.LastSeen
+}
+
+// Expiry returns the expiry datetime for the session.
+func (s *Session) Expiry() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.expiry(s.store.options.Absolute)
+}
+
+// Extend updates the session idle timeout.
+// The absolute lifetime of the session is not extended.
+func (s *Session) Extend(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl > 0 {
+		s.data.Idle = ttl
+		s.changed = true
+	}
+}
+
+// BindUser binds the session to the given user ID; an empty ID unbinds it.
+// Cookie sessions cannot be listed or revoked by user.
+func (s *Session) BindUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.UserID = userID
+	s.changed = true
+}
+
+// UserID returns the user ID the session is bound to, if any.
+func (s *Session) UserID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.UserID
+}
+
+// Regenerate issues a new session ID and rewrites the session cookies.
+// The previous cookies remain valid until they expire, so unlike server side
+// stores this does not protect against session fixation.
+func (s *Session) Regenerate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.ID == "" {
+		return nil
+	}
+
+	oldID := s.data.ID
+	data := s.data
+	data.ID = uuid.NewString()
+
+	if err := s.write(data); err != nil {
+		return err
+	}
+
+	s.store.options.Hooks.Regenerated(oldID, data.ID, data.Data)
+	return nil
+}
+
+// Destroy revokes the session cookies held by the client. Copies of the cookies
+// held elsewhere remain valid until they expire.
+func (s *Session) Destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store.revoke(s.res, 0, max(s.chunks, 1))
+
+	if s.data.ID != "" {
+		s.store.options.Hooks.Destroyed(s.data.ID, s.data.Data)
+	}
+
+	now := time.Now()
+	s.data = session{
+		Created:  now,
+		LastSeen: now,
+		Idle:     s.store.idle,
+		Data:     make(map[string][]byte),
+	}
+	s.chunks = 0
+	s.changed = false
+
+	return nil
+}
+
+// Save writes the session to the response cookies if it has changed and renews
+// the idle timeout.
+// Sessions without data or a bound user are removed from the client.
+// Returns ErrCookieTooLarge if the session cannot fit within the cookie limits.
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.changed {
+		return nil
+	}
+
+	if len(s.data.Data) == 0 && s.data.UserID == "" {
+		s.store.revoke(s.res, 0, s.chunks)
+		s.data.ID = ""
+		s.chunks = 0
+		s.changed = false
+		return nil
+	}
+
+	data := s.data
+	if data.ID != "" {
+		return s.write(data)
+	}
+
+	data.ID = uuid.NewString()
+	if err := s.write(data); err != nil {
+		return err
+	}
+
+	s.store.options.Hooks.Created(data.ID, data.Data)
+	return nil
+}
+
+// write sets the cookies for the given session data, revoking any unused chunks.
+// The idle timeout is renewed from the time of writing.
+func (s *Session) write(data session) error {
+	data.LastSeen = time.Now()
+
+	cookies, err := s.store.encode(data, time.Until(data.expiry(s.store.options.Absolute)))
+	if err != nil {
+		return err
+	}
+
+	for _, cookie := range cookies {
+		http.SetCookie(s.res, cookie)
+	}
+	s.store.revoke(s.res, len(cookies), s.chunks)
+
+	s.data = data
+	s.chunks = len(cookies)
+	s.changed = false
+
+	return nil
+}
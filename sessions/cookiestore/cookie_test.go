@@ -0,0 +1,369 @@
+package cookiestore_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/cookiestore"
+)
+
+func newRequest(cookies ...*http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range cookies {
+		if cookie.MaxAge >= 0 {
+			r.AddCookie(cookie)
+		}
+	}
+	return w, r
+}
+
+type testType struct {
+	Data int
+}
+
+var key = "test-key"
+
+var expected = testType{42}
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestCookieStore(t *testing.T) {
+	store, err := cookiestore.New(90*time.Millisecond, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key1})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Stop()
+
+	w, r := newRequest()
+
+	t.Run("set", func(t *testing.T) {
+		session := store.Session(w, r)
+
+		if err := session.Set(key, expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].Name != "test" {
+			t.Fatal("failed to set cookie")
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		w, r = newRequest(w.Result().Cookies()...)
+		session := store.Session(w, r)
+
+		var out testType
+		if err := session.Get(key, &out); err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected %#v got %#v", expected, out)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		cookie := *r.Cookies()[0]
+		cookie.Value = strings.ToUpper(cookie.Value)
+		_, r := newRequest(&cookie)
+
+		var out testType
+		if err := store.Session(w, r).Get(key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("extended & expired", func(t *testing.T) {
+		w, r = newRequest(r.Cookies()...)
+		session := store.Session(w, r)
+
+		session.Extend(180 * time.Millisecond)
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		time.Sleep(110 * time.Millisecond)
+
+		w, r = newRequest(w.Result().Cookies()...)
+		session = store.Session(w, r)
+
+		var out testType
+		if err := session.Get(key, &out); err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+
+		time.Sleep(90 * time.Millisecond)
+
+		w, r = newRequest(r.Cookies()...)
+		session = store.Session(w, r)
+
+		var out2 testType
+		if err := session.Get(key, &out2); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w, r = newRequest()
+		session := store.Session(w, r)
+
+		if err := session.Set(key, expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		w, r = newRequest(w.Result().Cookies()...)
+		session = store.Session(w, r)
+
+		session.Delete(key)
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+			t.Fatal("expected empty session cookie to be revoked")
+		}
+	})
+}
+
+func TestCookieStoreKeyRotation(t *testing.T) {
+	old, err := cookiestore.New(time.Minute, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key1})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	rotated, err := cookiestore.New(time.Minute, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key2, key1})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	w, r := newRequest()
+	session := old.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	w, r = newRequest(w.Result().Cookies()...)
+	session = rotated.Session(w, r)
+
+	var out testType
+	if err := session.Get(key, &out); err != nil {
+		t.Fatalf("failed to get data with old key: %v", err)
+	}
+
+	session.Extend(time.Minute)
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	_, r = newRequest(w.Result().Cookies()...)
+
+	if err := old.Session(w, r).Get(key, &out); err != sessions.ErrValueNotFound {
+		t.Fatalf("expected session encrypted with new key, got %v", err)
+	}
+}
+
+func TestCookieStoreSize(t *testing.T) {
+	large := strings.Repeat("x", 5000)
+
+	t.Run("too large", func(t *testing.T) {
+		store, err := cookiestore.New(time.Minute, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key1})
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+
+		w, r := newRequest()
+		session := store.Session(w, r)
+
+		if err := session.Set(key, large); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		if err := session.Save(); !errors.Is(err, cookiestore.ErrCookieTooLarge) {
+			t.Fatalf("expected cookie too large, got %v", err)
+		}
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		store, err := cookiestore.New(time.Minute, 3, sessions.CookieOpts{Name: "test"}, [][]byte{key1})
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+
+		w, r := newRequest()
+		session := store.Session(w, r)
+
+		if err := session.Set(key, large); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("expected 2 cookies, got %d", len(cookies))
+		}
+
+		for _, cookie := range cookies {
+			if len(cookie.String()) > cookiestore.MaxCookieSize {
+				t.Fatalf("cookie %s exceeds max size", cookie.Name)
+			}
+		}
+
+		w, r = newRequest(cookies...)
+		session = store.Session(w, r)
+
+		var out string
+		if err := session.Get(key, &out); err != nil || out != large {
+			t.Fatalf("failed to get chunked data: %v", err)
+		}
+
+		session.Set(key, "small")
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		cookies = w.Result().Cookies()
+		if len(cookies) != 2 || cookies[1].Name != "test_1" || cookies[1].MaxAge >= 0 {
+			t.Fatal("expected unused chunk to be revoked")
+		}
+	})
+}
+
+func TestCookieStoreRegenerate(t *testing.T) {
+	store, err := cookiestore.New(time.Minute, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key1})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	w, r := newRequest()
+	session := store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	old := w.Result().Cookies()[0]
+
+	w, r = newRequest(old)
+	session = store.Session(w, r)
+
+	if err := session.Regenerate(); err != nil {
+		t.Fatalf("failed to regenerate session: %v", err)
+	}
+
+	if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].Value == old.Value {
+		t.Fatal("failed to set regenerated cookie")
+	}
+
+	var out testType
+	if err := store.Session(newRequest(w.Result().Cookies()...)).Get(key, &out); err != nil {
+		t.Fatalf("failed to get data: %v", err)
+	}
+
+	w, r = newRequest(w.Result().Cookies()...)
+	session = store.Session(w, r)
+
+	if err := session.Destroy(); err != nil {
+		t.Fatalf("failed to destroy session: %v", err)
+	}
+
+	if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].MaxAge >= 0 {
+		t.Fatal("failed to revoke cookie")
+	}
+}
+
+func TestCookieStoreTimeouts(t *testing.T) {
+	store, err := cookiestore.New(200*time.Millisecond, 1, sessions.CookieOpts{Name: "test"}, [][]byte{key1}, sessions.WithAbsolute(400*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Stop()
+
+	w, r := newRequest()
+	session := store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	created := session.CreatedAt()
+	lastSeen := session.LastSeen()
+	cookie := w.Result().Cookies()[0]
+
+	t.Run("idle renewed", func(t *testing.T) {
+		for range 3 {
+			time.Sleep(120 * time.Millisecond)
+
+			w, r = newRequest(cookie)
+			session := store.Session(w, r)
+
+			var out testType
+			if err := session.Get(key, &out); err != nil {
+				t.Fatalf("failed to get data: %v", err)
+			}
+
+			if err := session.Save(); err != nil {
+				t.Fatalf("failed to save session: %v", err)
+			}
+
+			if !session.CreatedAt().Equal(created) {
+				t.Fatal("expected created datetime to be unchanged")
+			}
+
+			if !session.LastSeen().After(lastSeen) {
+				t.Fatal("expected last seen datetime to be renewed")
+			}
+
+			lastSeen = session.LastSeen()
+			cookie = w.Result().Cookies()[0]
+		}
+	})
+
+	t.Run("absolute expired", func(t *testing.T) {
+		time.Sleep(80 * time.Millisecond)
+
+		var out testType
+		if err := store.Session(newRequest(cookie)).Get(key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+}
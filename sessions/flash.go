@@ -0,0 +1,110 @@
+package sessions
+
+import "time"
+
+// flashesKey maps to the pending flash messages in the session.
+var flashesKey = NewKey[[]Flash]("sittella.flashes")
+
+// Flash defines a one-time message stored in the session for the next request,
+// typically to be displayed after a redirect.
+type Flash struct {
+	// Kind specifies the category of the message (e.g. "success", "error").
+	Kind string
+
+	// Message specifies the message text.
+	Message string
+
+	// Payload specifies optional typed data encoded with AddFlashPayload.
+	Payload []byte
+
+	// Added specifies the datetime the message was added.
+	Added time.Time
+}
+
+// AddFlash adds a flash message of the given kind to the session.
+func AddFlash(s Session, kind, message string) error {
+	return addFlash(s, Flash{Kind: kind, Message: message})
+}
+
+// AddFlashPayload adds a flash message of the given kind with a typed payload to
+// the session. The payload is encoded with the session codec if the session
+// implements CodecProvider, otherwise GobCodec, and can be read with FlashPayload.
+func AddFlashPayload[T any](s Session, kind, message string, payload T) error {
+	codec := GobCodec
+	if provider, ok := s.(CodecProvider); ok {
+		codec = provider.Codec()
+	}
+
+	encoded, err := Encode(codec, payload)
+	if err != nil {
+		return err
+	}
+	return addFlash(s, Flash{Kind: kind, Message: message, Payload: encoded})
+}
+
+// Flashes returns the pending flash messages and removes them from the session.
+func Flashes(s Session) ([]Flash, error) {
+	flashes, err := Value(s, flashesKey)
+	if err == ErrValueNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Delete(flashesKey.Name())
+	return flashes, nil
+}
+
+// ExpireFlashes removes the flash messages added to the session before the given
+// datetime. Calling it with the request start time before the session is saved
+// at the end of each request limits flashes to the request that follows the one
+// adding them, whether or not they are read.
+func ExpireFlashes(s Session, before time.Time) error {
+	flashes, err := Value(s, flashesKey)
+	if err == ErrValueNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var pending []Flash
+	for _, flash := range flashes {
+		if !flash.Added.Before(before) {
+			pending = append(pending, flash)
+		}
+	}
+
+	switch len(pending) {
+	case len(flashes):
+		return nil
+	case 0:
+		s.Delete(flashesKey.Name())
+		return nil
+	default:
+		return SetValue(s, flashesKey, pending)
+	}
+}
+
+// FlashPayload decodes the typed payload of the flash message.
+// Returns ErrValueNotFound if the message has no payload.
+func FlashPayload[T any](flash Flash) (T, error) {
+	var payload T
+	if len(flash.Payload) == 0 {
+		return payload, ErrValueNotFound
+	}
+	err := Decode(flash.Payload, &payload)
+	return payload, err
+}
+
+// addFlash appends the flash message to the pending messages in the session.
+func addFlash(s Session, flash Flash) error {
+	flashes, err := Value(s, flashesKey)
+	if err != nil && err != ErrValueNotFound {
+		return err
+	}
+
+	flash.Added = time.Now()
+	return SetValue(s, flashesKey, append(flashes, flash))
+}
@@ -0,0 +1,127 @@
+package sessions_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/memorystore"
+)
+
+func TestFlashes(t *testing.T) {
+	store := memorystore.New(time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	defer store.Stop()
+
+	// request adding the flashes before redirecting.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", nil)
+	sess := store.Session(w, r)
+
+	if err := sessions.AddFlash(sess, "success", "saved"); err != nil {
+		t.Fatalf("failed to add flash: %v", err)
+	}
+	if err := sessions.AddFlashPayload(sess, "error", "invalid", expected); err != nil {
+		t.Fatalf("failed to add flash payload: %v", err)
+	}
+	if err := sess.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	// redirected request consuming the flashes.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	sess = store.Session(w, r)
+
+	flashes, err := sessions.Flashes(sess)
+	if err != nil {
+		t.Fatalf("failed to get flashes: %v", err)
+	}
+	if len(flashes) != 2 || flashes[0].Kind != "success" || flashes[0].Message != "saved" || flashes[1].Kind != "error" {
+		t.Fatalf("unexpected flashes %#v", flashes)
+	}
+
+	if _, err := sessions.FlashPayload[testType](flashes[0]); err != sessions.ErrValueNotFound {
+		t.Fatalf("expected %v got %v", sessions.ErrValueNotFound, err)
+	}
+	payload, err := sessions.FlashPayload[testType](flashes[1])
+	if err != nil {
+		t.Fatalf("failed to decode flash payload: %v", err)
+	}
+	if !reflect.DeepEqual(expected, payload) {
+		t.Fatalf("expected %#v got %#v", expected, payload)
+	}
+
+	if err := sess.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	// subsequent request no longer has the flashes.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	sess = store.Session(w, r)
+
+	flashes, err = sessions.Flashes(sess)
+	if err != nil {
+		t.Fatalf("failed to get flashes: %v", err)
+	}
+	if len(flashes) != 0 {
+		t.Fatalf("expected no flashes got %#v", flashes)
+	}
+}
+
+func TestFlashPayloadCodec(t *testing.T) {
+	store := memorystore.New(time.Minute, time.Minute, sessions.CookieOpts{Name: "test"}, sessions.WithCodec(sessions.JSONCodec))
+	defer store.Stop()
+
+	sess := store.Session(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err := sessions.AddFlashPayload(sess, "error", "invalid", expected); err != nil {
+		t.Fatalf("failed to add flash payload: %v", err)
+	}
+
+	flashes, err := sessions.Flashes(sess)
+	if err != nil || len(flashes) != 1 {
+		t.Fatalf("failed to get flashes: %v", err)
+	}
+
+	if payload := flashes[0].Payload; len(payload) < 3 || payload[2] != sessions.JSONCodec.ID() {
+		t.Fatalf("expected payload encoded with the store codec, got %q", payload)
+	}
+
+	payload, err := sessions.FlashPayload[testType](flashes[0])
+	if err != nil || !reflect.DeepEqual(expected, payload) {
+		t.Fatalf("expected %#v got %#v: %v", expected, payload, err)
+	}
+}
+
+func TestExpireFlashes(t *testing.T) {
+	store := memorystore.New(time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	defer store.Stop()
+
+	sess := store.Session(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err := sessions.AddFlash(sess, "success", "old"); err != nil {
+		t.Fatalf("failed to add flash: %v", err)
+	}
+
+	before := time.Now()
+	if err := sessions.AddFlash(sess, "success", "new"); err != nil {
+		t.Fatalf("failed to add flash: %v", err)
+	}
+
+	if err := sessions.ExpireFlashes(sess, before); err != nil {
+		t.Fatalf("failed to expire flashes: %v", err)
+	}
+
+	flashes, err := sessions.Flashes(sess)
+	if err != nil || len(flashes) != 1 || flashes[0].Message != "new" {
+		t.Fatalf("expected only the new flash, got %#v: %v", flashes, err)
+	}
+
+	if err := sessions.ExpireFlashes(sess, time.Now()); err != nil {
+		t.Fatalf("failed to expire flashes without any: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package sessions
+
+import "maps"
+
+// Snapshot holds the encoded values of a session at the time of a lifecycle event.
+type Snapshot map[string][]byte
+
+// Get retrieves and decodes the value mapped to by the given key if it exists.
+func (s Snapshot) Get(key string, dest any) error {
+	data, ok := s[key]
+	if !ok {
+		return ErrValueNotFound
+	}
+	return Decode(data, dest)
+}
+
+// Hooks specifies the functions called on session lifecycle events.
+// Hooks are called synchronously after the event has been applied to the store,
+// so they must not block for long. Any hook may be nil.
+type Hooks struct {
+	// OnCreate is called when a new session is first stored.
+	OnCreate func(id string, data Snapshot)
+
+	// OnExpire is called when an expired session is removed from the store.
+	OnExpire func(id string, data Snapshot)
+
+	// OnDestroy is called when a session is destroyed, revoked or evicted to
+	// satisfy the limits of the store.
+	OnDestroy func(id string, data Snapshot)
+
+	// OnRegenerate is called when a session is moved to a new ID.
+	OnRegenerate func(oldID, newID string, data Snapshot)
+}
+
+// Created calls OnCreate with a copy of the session data if it is set.
+func (h Hooks) Created(id string, data map[string][]byte) {
+	if h.OnCreate != nil {
+		h.OnCreate(id, maps.Clone(data))
+	}
+}
+
+// Expired calls OnExpire with a copy of the session data if it is set.
+func (h Hooks) Expired(id string, data map[string][]byte) {
+	if h.OnExpire != nil {
+		h.OnExpire(id, maps.Clone(data))
+	}
+}
+
+// Destroyed calls OnDestroy with a copy of the session data if it is set.
+func (h Hooks) Destroyed(id string, data map[string][]byte) {
+	if h.OnDestroy != nil {
+		h.OnDestroy(id, maps.Clone(data))
+	}
+}
+
+// Regenerated calls OnRegenerate with a copy of the session data if it is set.
+func (h Hooks) Regenerated(oldID, newID string, data map[string][]byte) {
+	if h.OnRegenerate != nil {
+		h.OnRegenerate(oldID, newID, maps.Clone(data))
+	}
+}
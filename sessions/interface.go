@@ -1,6 +1,7 @@
 package sessions
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -8,7 +9,17 @@ import (
 
 var ErrValueNotFound = errors.New("value not found")
 
+var ErrNotSupported = errors.New("not supported by session store")
+
+var ErrConflict = errors.New("session modified concurrently")
+
 // Store specifies an interface to retrieve or generate request scoped sessions.
+//
+// Overlapping requests for the same session each hold their own copy of it.
+// Implementations must not silently drop the changes of one request when another
+// saves: Save should merge the changed keys into the stored session, or return
+// ErrConflict if the changes cannot be applied. Stores that keep the session on
+// the client, where this cannot be guaranteed, must document it.
 type Store interface {
 	// Session retrieves or generates a new session for the current request.
 	Session(w http.ResponseWriter, r *http.Request) Session
@@ -31,12 +42,114 @@ type Session interface {
 	// Clear removes all key value pairs from the session.
 	Clear()
 
-	// Expiry returns the expiry datetime for the session.
+	// CreatedAt returns the datetime the session was created.
+	CreatedAt() time.Time
+
+	// LastSeen returns the datetime the session was last saved.
+	LastSeen() time.Time
+
+	// Expiry returns the expiry datetime for the session, being the earlier of
+	// the idle timeout and the absolute lifetime.
 	Expiry() time.Time
 
-	// Extend updates the session expiry.
+	// Extend updates the session idle timeout.
+	// The absolute lifetime of the session is not extended.
 	Extend(ttl time.Duration)
 
+	// Regenerate issues a new session ID, migrating the session data to it and
+	// removing the old session from the store. It should be called whenever the
+	// privilege level of the session changes, such as on login, to prevent
+	// session fixation.
+	Regenerate() error
+
+	// Destroy removes the session from the store and revokes the session cookie.
+	// The session is left empty and may be reused for a new session.
+	Destroy() error
+
 	// Save persists any changes made to the session in the store.
+	// Sessions are saved automatically immediately before the response is written,
+	// so Save only needs to be called to persist changes made after that point.
 	Save() error
 }
+
+// SessionInfo describes a stored session.
+type SessionInfo struct {
+	// ID specifies the session ID.
+	ID string
+
+	// UserID specifies the user the session is bound to.
+	UserID string
+
+	// CreatedAt specifies the datetime the session was created.
+	CreatedAt time.Time
+
+	// LastSeen specifies the datetime the session was last saved.
+	LastSeen time.Time
+
+	// Expiry specifies the expiry datetime for the session.
+	Expiry time.Time
+
+	// IP specifies the client IP address the session was last saved from.
+	IP string
+
+	// UserAgent specifies the client user agent the session was last saved from.
+	UserAgent string
+}
+
+// UserBinder is an optional Session capability to bind a session to a user.
+type UserBinder interface {
+	// BindUser binds the session to the given user ID; an empty ID unbinds it.
+	// The binding is persisted when the session is saved.
+	BindUser(userID string)
+
+	// UserID returns the user ID the session is bound to, if any.
+	UserID() string
+}
+
+// UserIndexer is an optional Store capability to list and revoke the sessions
+// bound to a user, such as after a password change.
+type UserIndexer interface {
+	// SessionsForUser returns the active sessions bound to the given user ID.
+	SessionsForUser(ctx context.Context, userID string) ([]SessionInfo, error)
+
+	// RevokeUser removes all sessions bound to the given user ID.
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// Detector is an optional Store capability to report whether a request carries a
+// session cookie without loading the session. It allows flash messages to expire
+// on requests that do not use the session.
+type Detector interface {
+	// HasSession returns true if the request carries a session cookie.
+	HasSession(r *http.Request) bool
+}
+
+// CodecProvider is an optional Session capability exposing the codec the
+// session values are encoded with.
+type CodecProvider interface {
+	// Codec returns the codec used to encode session values.
+	Codec() Codec
+}
+
+// renewInterval specifies the longest time an active session goes without its
+// idle timeout being renewed.
+const renewInterval = time.Minute
+
+// Renew reports whether a session last saved at lastSeen should have its idle
+// timeout renewed when it is next loaded: once a tenth of the idle timeout or a
+// minute has passed, whichever is sooner. Stores use it so that loading a session
+// does not rewrite it and its cookie on every request.
+func Renew(lastSeen time.Time, idle time.Duration) bool {
+	return time.Since(lastSeen) >= min(idle/10, renewInterval)
+}
+
+// BindUser binds the session to the given user ID.
+// Returns ErrNotSupported if the session does not implement UserBinder.
+func BindUser(s Session, userID string) error {
+	binder, ok := s.(UserBinder)
+	if !ok {
+		return ErrNotSupported
+	}
+	binder.BindUser(userID)
+	return nil
+}
@@ -0,0 +1,24 @@
+package sessions
+
+// Key is a typed key for values held in a Session.
+// The value type is fixed by the key, so mismatched reads and writes are caught
+// at compile time rather than when the value is decoded.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new typed session key with the given name.
+func NewKey[T any](name string) Key[T] { return Key[T]{name: name} }
+
+// Name returns the name the key is stored under.
+func (k Key[T]) Name() string { return k.name }
+
+// Value retrieves and decodes the value mapped to by the given key if it exists.
+func Value[T any](s Session, key Key[T]) (T, error) {
+	var value T
+	err := s.Get(key.name, &value)
+	return value, err
+}
+
+// SetValue adds or updates the key value pair to the session.
+func SetValue[T any](s Session, key Key[T], value T) error { return s.Set(key.name, value) }
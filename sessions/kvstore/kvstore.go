@@ -0,0 +1,712 @@
+package kvstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// KV specifies an interface to a key value backend that sessions can be stored in.
+type KV interface {
+	// Get retrieves the unexpired value mapped to by the given key.
+	// Returns ErrNotFound if the key does not exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set adds or updates the key value pair, expiring it after ttl.
+	// A ttl of zero or less stores the pair without expiry.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the value mapped to by the given key if it exists.
+	Delete(ctx context.Context, key string) error
+
+	// Scan returns the unexpired keys starting with the given prefix.
+	Scan(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Swapper specifies an optional KV capability to atomically replace a value.
+// Stores backed by a KV implementing Swapper merge concurrent saves safely;
+// otherwise the merge is best effort and a save may overwrite changes made by an
+// overlapping request between loading and writing the stored session.
+type Swapper interface {
+	// CompareAndSwap replaces the value mapped to by the given key with value if
+	// it is currently equal to old, expiring it after ttl. A nil old value
+	// requires that the key does not exist. Returns false if the value differs.
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+// Notifier specifies an optional KV capability to report the values the KV
+// removes itself. Stores backed by a KV implementing Notifier call the OnExpire
+// hook for sessions that expire and the OnDestroy hook for sessions that are
+// evicted, and remove them from the user index.
+type Notifier interface {
+	// Notify registers fn to be called with each key and value the KV removes
+	// itself, once the KV is safe to use again. expired is true if the value was
+	// removed because it expired, and false if it was evicted.
+	Notify(fn func(key string, value []byte, expired bool))
+}
+
+// maxAttempts specifies how many times a save is retried when the stored session
+// is modified concurrently.
+const maxAttempts = 5
+
+// record is the serialised form of a session in the KV.
+type record struct {
+	ID        string
+	UserID    string
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Idle      time.Duration
+	Data      map[string][]byte
+}
+
+// expiry returns the earlier of the idle and absolute expiry datetimes.
+func (r record) expiry(absolute time.Duration) time.Time {
+	expiry := r.LastSeen.Add(r.Idle)
+	if absolute > 0 && r.Created.Add(absolute).Before(expiry) {
+		return r.Created.Add(absolute)
+	}
+	return expiry
+}
+
+// Store defines a session store backed by a KV to retrieve or generate request
+// scoped sessions.
+//
+// Expired sessions are removed by the KV itself, so the OnExpire hook is only
+// called if the KV implements Notifier.
+type Store struct {
+	kv      KV
+	prefix  string
+	cookie  sessions.CookieOpts
+	idle    time.Duration
+	options sessions.Options
+}
+
+// New returns a new KV backed session Store.
+// Parameters:
+// - kv specifies the backend the sessions are stored in.
+// - prefix specifies the prefix of all keys written by the store, allowing a KV to be shared.
+// - idle specifies how long a session lives without activity; it is renewed each time the session is saved.
+// - cookie specifies the cookie options to be used.
+// - opts specify optional store settings such as the value codec, absolute lifetime and lifecycle hooks.
+func New(kv KV, prefix string, idle time.Duration, cookie sessions.CookieOpts, opts ...sessions.Option) *Store {
+	store := &Store{
+		kv:      kv,
+		prefix:  prefix,
+		cookie:  cookie,
+		idle:    idle,
+		options: sessions.NewOptions(opts...),
+	}
+
+	if notifier, ok := kv.(Notifier); ok {
+		notifier.Notify(store.removed)
+	}
+
+	return store
+}
+
+// Session retrieves the session for the current request, or generates a new
+// session if none exists. New sessions are not stored and no cookie is set until
+// data is saved to them. Existing sessions are renewed once enough of their idle
+// timeout has passed, see sessions.Renew.
+func (s *Store) Session(w http.ResponseWriter, r *http.Request) sessions.Session {
+	sess := &Session{
+		req:   r,
+		res:   w,
+		store: s,
+		mu:    &sync.RWMutex{},
+	}
+
+	cookie, _ := r.Cookie(s.cookie.Name)
+	if cookie != nil {
+		data, _, err := s.load(r.Context(), cookie.Value)
+		if err == nil {
+			sess.data = data
+			sess.changed = sessions.Renew(data.LastSeen, data.Idle)
+			return sess
+		}
+		if !errors.Is(err, ErrNotFound) {
+			slog.Error("kvstore: failed to load session", "error", err)
+		}
+	}
+
+	// new sessions are only assigned an ID and cookie once data is saved to them.
+	now := time.Now()
+	sess.data = record{
+		Created:  now,
+		LastSeen: now,
+		Idle:     s.idle,
+		Data:     make(map[string][]byte),
+	}
+
+	return sess
+}
+
+// HasSession returns true if the request carries a session cookie.
+func (s *Store) HasSession(r *http.Request) bool {
+	_, err := r.Cookie(s.cookie.Name)
+	return err == nil
+}
+
+// Stop releases any goroutines and resources allocated by the Store.
+// The KV is not closed.
+func (s *Store) Stop() {}
+
+// SessionsForUser returns the active sessions bound to the given user ID.
+func (s *Store) SessionsForUser(ctx context.Context, userID string) ([]sessions.SessionInfo, error) {
+	ids, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []sessions.SessionInfo
+	for _, id := range ids {
+		data, _, err := s.load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data.UserID != userID {
+			continue
+		}
+
+		infos = append(infos, sessions.SessionInfo{
+			ID:        data.ID,
+			UserID:    data.UserID,
+			CreatedAt: data.Created,
+			LastSeen:  data.LastSeen,
+			Expiry:    data.expiry(s.options.Absolute),
+			IP:        data.IP,
+			UserAgent: data.UserAgent,
+		})
+	}
+
+	return infos, nil
+}
+
+// RevokeUser removes all sessions bound to the given user ID, calling the
+// OnDestroy hook for each.
+func (s *Store) RevokeUser(ctx context.Context, userID string) error {
+	if userID == "" {
+		return nil
+	}
+
+	ids, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		data, _, err := s.load(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err == nil && data.UserID != userID {
+			continue
+		}
+
+		if err := s.kv.Delete(ctx, s.sessionKey(id)); err != nil {
+			return err
+		}
+		if err := s.kv.Delete(ctx, s.userKey(userID, id)); err != nil {
+			return err
+		}
+
+		if data.ID != "" {
+			s.options.Hooks.Destroyed(data.ID, data.Data)
+		}
+	}
+
+	return nil
+}
+
+// SessionPrefix returns the prefix of the keys sessions are stored under, as
+// opposed to the keys indexing them by user.
+func (s *Store) SessionPrefix() string { return s.prefix + "session:" }
+
+// sessionKey returns the key the session with the given id is stored under.
+func (s *Store) sessionKey(id string) string { return s.SessionPrefix() + id }
+
+// userKey returns the key indexing the session with the given id by its user.
+func (s *Store) userKey(userID, id string) string { return s.prefix + "user:" + userID + ":" + id }
+
+// userSessions returns the IDs of the sessions indexed under the given user ID.
+// The IDs of users sharing a prefix with userID may be included, so the user ID
+// of each session must be checked.
+func (s *Store) userSessions(ctx context.Context, userID string) ([]string, error) {
+	keys, err := s.kv.Scan(ctx, s.prefix+"user:"+userID+":")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key[strings.LastIndexByte(key, ':')+1:])
+	}
+
+	return ids, nil
+}
+
+// removed calls the lifecycle hook for a session removed by the KV itself and
+// removes it from the user index.
+func (s *Store) removed(key string, value []byte, expired bool) {
+	if !strings.HasPrefix(key, s.SessionPrefix()) {
+		return
+	}
+
+	data, err := decode(value)
+	if err != nil {
+		slog.Error("kvstore: failed to decode removed session", "error", err)
+		return
+	}
+
+	if data.UserID != "" {
+		if err := s.kv.Delete(context.Background(), s.userKey(data.UserID, data.ID)); err != nil {
+			slog.Error("kvstore: failed to unindex removed session", "error", err)
+		}
+	}
+
+	if expired {
+		s.options.Hooks.Expired(data.ID, data.Data)
+	} else {
+		s.options.Hooks.Destroyed(data.ID, data.Data)
+	}
+}
+
+// load retrieves the session with the given id and its encoded form.
+func (s *Store) load(ctx context.Context, id string) (record, []byte, error) {
+	raw, err := s.kv.Get(ctx, s.sessionKey(id))
+	if err != nil {
+		return record{}, nil, err
+	}
+
+	data, err := decode(raw)
+	if err != nil {
+		return record{}, nil, err
+	}
+
+	return data, raw, nil
+}
+
+// decode returns the session encoded in raw.
+func decode(raw []byte) (record, error) {
+	var data record
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		return record{}, err
+	}
+	if data.Data == nil {
+		data.Data = make(map[string][]byte)
+	}
+
+	return data, nil
+}
+
+// write stores the session, replacing old if the KV supports it. A nil old
+// value requires that the session does not exist. Returns false if the stored
+// session differs from old.
+func (s *Store) write(ctx context.Context, data record, old []byte) (bool, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return false, err
+	}
+
+	key := s.sessionKey(data.ID)
+	ttl := time.Until(data.expiry(s.options.Absolute))
+
+	if swapper, ok := s.kv.(Swapper); ok {
+		return swapper.CompareAndSwap(ctx, key, old, buf.Bytes(), ttl)
+	}
+
+	return true, s.kv.Set(ctx, key, buf.Bytes(), ttl)
+}
+
+// index updates the user index for the session, which was previously bound to
+// the user with the given ID.
+func (s *Store) index(ctx context.Context, data record, oldID, oldUserID string) error {
+	if oldUserID != "" && (oldUserID != data.UserID || oldID != data.ID) {
+		if err := s.kv.Delete(ctx, s.userKey(oldUserID, oldID)); err != nil {
+			return err
+		}
+	}
+
+	if data.UserID == "" {
+		return nil
+	}
+
+	return s.kv.Set(ctx, s.userKey(data.UserID, data.ID), []byte(data.ID), time.Until(data.expiry(s.options.Absolute)))
+}
+
+// create stores a new session.
+func (s *Store) create(ctx context.Context, data record) error {
+	ok, err := s.write(ctx, data, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sessions.ErrConflict
+	}
+
+	return s.index(ctx, data, data.ID, "")
+}
+
+// update replaces the unexpired session with the given id with the result of fn,
+// which receives the stored session. If the result has a different id, the
+// session is moved to it, returning ErrConflict if a session already exists with
+// that id. The update is retried if another request saved the session in the
+// meantime. Returns false if the session does not exist.
+func (s *Store) update(ctx context.Context, id string, fn func(stored record) record) (record, bool, error) {
+	for range maxAttempts {
+		stored, raw, err := s.load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return record{}, false, nil
+		}
+		if err != nil {
+			return record{}, false, err
+		}
+
+		data := fn(stored)
+
+		if data.ID != id {
+			ok, err := s.write(ctx, data, nil)
+			if err != nil {
+				return record{}, false, err
+			}
+			if !ok {
+				return record{}, false, sessions.ErrConflict
+			}
+			if err := s.kv.Delete(ctx, s.sessionKey(id)); err != nil {
+				return record{}, false, err
+			}
+		} else {
+			ok, err := s.write(ctx, data, raw)
+			if err != nil {
+				return record{}, false, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		if err := s.index(ctx, data, id, stored.UserID); err != nil {
+			return record{}, false, err
+		}
+
+		return data, true, nil
+	}
+
+	return record{}, false, sessions.ErrConflict
+}
+
+// Session defines a KV backed request scoped session.
+// Changes are tracked per key and merged into the stored session on save, so
+// overlapping requests for the same session do not overwrite each other.
+type Session struct {
+	req     *http.Request
+	res     http.ResponseWriter
+	store   *Store
+	data    record
+	mu      *sync.RWMutex
+	changed bool
+
+	// keys, cleared, userChanged and idleChanged track the changes to merge on save.
+	keys        map[string]struct{}
+	cleared     bool
+	userChanged bool
+	idleChanged bool
+}
+
+// Set adds or updates the key value pair to the session.
+func (s *Session) Set(key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoded, err := sessions.Encode(s.store.options.Codec, value)
+	if err != nil {
+		return err
+	}
+
+	s.data.Data[key] = encoded
+
+	s.track(key)
+
+	return nil
+}
+
+// Get retrieves and decodes the value mapped to by the given key if it exists.
+func (s *Session) Get(key string, dest any) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data.Data[key]
+	if !ok {
+		return sessions.ErrValueNotFound
+	}
+
+	return sessions.Decode(data, dest)
+}
+
+// Delete removes the value mapped to by the given key from the session.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Data, key)
+
+	s.track(key)
+}
+
+// Clear removes all key value pairs from the session.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Data = make(map[string][]byte)
+
+	s.keys = nil
+	s.cleared = true
+	s.changed = true
+}
+
+// Codec returns the codec used to encode session values.
+func (s *Session) Codec() sessions.Codec { return s.store.options.Codec }
+
+// CreatedAt returns the datetime the session was created.
+func (s *Session) CreatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.Created
+}
+
+// LastSeen returns the datetime the session was last saved.
+func (s *Session) LastSeen() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.LastSeen
+}
+
+// Expiry returns the expiry datetime for the session.
+func (s *Session) Expiry() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.expiry(s.store.options.Absolute)
+}
+
+// Extend updates the session idle timeout.
+// The absolute lifetime of the session is not extended.
+func (s *Session) Extend(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl > 0 {
+		s.data.Idle = ttl
+		s.idleChanged = true
+		s.changed = true
+	}
+}
+
+// BindUser binds the session to the given user ID; an empty ID unbinds it.
+func (s *Session) BindUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.UserID = userID
+	s.userChanged = true
+	s.changed = true
+}
+
+// UserID returns the user ID the session is bound to, if any.
+func (s *Session) UserID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.UserID
+}
+
+// Regenerate issues a new session ID, migrating the session data to it and
+// removing the old session from the store.
+func (s *Session) Regenerate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.ID == "" {
+		return nil
+	}
+
+	return s.save(uuid.NewString())
+}
+
+// Destroy removes the session from the store and revokes the session cookie.
+func (s *Session) Destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.ID != "" {
+		ctx := s.req.Context()
+
+		stored, _, err := s.store.load(ctx, s.data.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		if err := s.store.kv.Delete(ctx, s.store.sessionKey(s.data.ID)); err != nil {
+			return err
+		}
+
+		if err == nil {
+			if stored.UserID != "" {
+				if err := s.store.kv.Delete(ctx, s.store.userKey(stored.UserID, stored.ID)); err != nil {
+					return err
+				}
+			}
+			s.store.options.Hooks.Destroyed(stored.ID, stored.Data)
+		}
+	}
+
+	s.reset()
+
+	return nil
+}
+
+// Save merges any changes made to the session into the stored session and
+// renews the idle timeout. If the session was removed from the store by another
+// request, such as by RevokeUser, the changes are discarded and the session
+// cookie is revoked.
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.changed {
+		return nil
+	}
+
+	if s.data.ID == "" {
+		if len(s.data.Data) == 0 && s.data.UserID == "" {
+			s.changed = false
+			return nil
+		}
+		return s.save(uuid.NewString())
+	}
+
+	return s.save(s.data.ID)
+}
+
+// track records a change to the given key.
+func (s *Session) track(key string) {
+	if s.keys == nil {
+		s.keys = make(map[string]struct{})
+	}
+	s.keys[key] = struct{}{}
+	s.changed = true
+}
+
+// merge applies the tracked changes on top of the stored session.
+func (s *Session) merge(stored record) record {
+	merged := stored
+	if s.cleared {
+		merged.Data = make(map[string][]byte)
+	} else {
+		merged.Data = maps.Clone(stored.Data)
+	}
+
+	for key := range s.keys {
+		if value, ok := s.data.Data[key]; ok {
+			merged.Data[key] = value
+		} else {
+			delete(merged.Data, key)
+		}
+	}
+
+	if s.userChanged {
+		merged.UserID = s.data.UserID
+	}
+	if s.idleChanged {
+		merged.Idle = s.data.Idle
+	}
+
+	return merged
+}
+
+// save merges the session into the store under the given id and sets the
+// session cookie.
+func (s *Session) save(id string) error {
+	prepare := func(data record) record {
+		data.ID = id
+		data.LastSeen = time.Now()
+		data.IP, _, _ = net.SplitHostPort(s.req.RemoteAddr)
+		data.UserAgent = s.req.UserAgent()
+		return data
+	}
+
+	var data record
+	if s.data.ID == "" {
+		data = prepare(s.merge(s.data))
+		if err := s.store.create(s.req.Context(), data); err != nil {
+			return err
+		}
+		s.store.options.Hooks.Created(data.ID, data.Data)
+	} else {
+		var ok bool
+		var err error
+		data, ok, err = s.store.update(s.req.Context(), s.data.ID, func(stored record) record {
+			return prepare(s.merge(stored))
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			s.reset()
+			return nil
+		}
+		if data.ID != s.data.ID {
+			s.store.options.Hooks.Regenerated(s.data.ID, data.ID, data.Data)
+		}
+	}
+
+	http.SetCookie(s.res, s.store.cookie.ToCookie(data.ID, time.Until(data.expiry(s.store.options.Absolute))))
+
+	s.data = data
+	s.changed = false
+	s.keys = nil
+	s.cleared = false
+	s.userChanged = false
+	s.idleChanged = false
+
+	return nil
+}
+
+// reset revokes the session cookie and replaces the session with a new empty session.
+func (s *Session) reset() {
+	http.SetCookie(s.res, s.store.cookie.ToRevoked())
+
+	now := time.Now()
+	s.data = record{
+		Created:  now,
+		LastSeen: now,
+		Idle:     s.store.idle,
+		Data:     make(map[string][]byte),
+	}
+	s.changed = false
+	s.keys = nil
+	s.cleared = false
+	s.userChanged = false
+	s.idleChanged = false
+}
@@ -0,0 +1,54 @@
+package kvstore_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+	"github.com/dimmerz92/sittella/sessions/kvstore/memorykv"
+	"github.com/dimmerz92/sittella/sessions/sessiontest"
+)
+
+func TestKVStore(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T, idle time.Duration, opts ...sessions.Option) sessions.Store {
+		kv := memorykv.New(time.Minute)
+		t.Cleanup(kv.Close)
+
+		return kvstore.New(kv, "test:", idle, sessions.CookieOpts{Name: "test"}, opts...)
+	})
+}
+
+func TestKVStoreNotifier(t *testing.T) {
+	kv := memorykv.New(20 * time.Millisecond)
+	defer kv.Close()
+
+	var recorder sessiontest.HookRecorder
+	store := kvstore.New(kv, "test:", 100*time.Millisecond, sessions.CookieOpts{Name: "test"}, sessions.WithHooks(recorder.Hooks()))
+	defer store.Stop()
+
+	session := store.Session(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if err := session.Set(sessiontest.Key, sessiontest.Expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := sessions.BindUser(session, "user-1"); err != nil {
+		t.Fatalf("failed to bind user: %v", err)
+	}
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	events := []string{"create", "expire"}
+	if got := recorder.Events(); !reflect.DeepEqual(events, got) {
+		t.Fatalf("expected %v got %v", events, got)
+	}
+
+	if keys, err := kv.Scan(t.Context(), "test:"); err != nil || len(keys) != 0 {
+		t.Fatalf("expected the expired session and its index to be removed, got %v %v", keys, err)
+	}
+}
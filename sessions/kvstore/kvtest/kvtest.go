@@ -0,0 +1,132 @@
+// Package kvtest provides a conformance test suite for kvstore.KV implementations.
+package kvtest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+)
+
+// Run tests that kv behaves as specified by kvstore.KV, and kvstore.Swapper if
+// it is implemented. The keys used are prefixed with "kvtest:".
+func Run(t *testing.T, kv kvstore.KV) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	t.Run("get set delete", func(t *testing.T) {
+		if _, err := kv.Get(ctx, "kvtest:missing"); err != kvstore.ErrNotFound {
+			t.Fatalf("expected %v got %v", kvstore.ErrNotFound, err)
+		}
+
+		for _, value := range []string{"value", "updated"} {
+			if err := kv.Set(ctx, "kvtest:key", []byte(value), 0); err != nil {
+				t.Fatalf("failed to set value: %v", err)
+			}
+
+			got, err := kv.Get(ctx, "kvtest:key")
+			if err != nil {
+				t.Fatalf("failed to get value: %v", err)
+			}
+			if string(got) != value {
+				t.Fatalf("expected %q got %q", value, got)
+			}
+		}
+
+		if err := kv.Delete(ctx, "kvtest:key"); err != nil {
+			t.Fatalf("failed to delete value: %v", err)
+		}
+		if _, err := kv.Get(ctx, "kvtest:key"); err != kvstore.ErrNotFound {
+			t.Fatalf("expected %v got %v", kvstore.ErrNotFound, err)
+		}
+		if err := kv.Delete(ctx, "kvtest:key"); err != nil {
+			t.Fatalf("failed to delete missing value: %v", err)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		if err := kv.Set(ctx, "kvtest:ttl", []byte("value"), 50*time.Millisecond); err != nil {
+			t.Fatalf("failed to set value: %v", err)
+		}
+		if _, err := kv.Get(ctx, "kvtest:ttl"); err != nil {
+			t.Fatalf("failed to get value: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if _, err := kv.Get(ctx, "kvtest:ttl"); err != kvstore.ErrNotFound {
+			t.Fatalf("expected %v got %v", kvstore.ErrNotFound, err)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		for _, key := range []string{"kvtest:scan:a", "kvtest:scan:b", "kvtest:scan_*:c", "kvtest:SCAN:d", "kvtest:other"} {
+			if err := kv.Set(ctx, key, []byte("value"), 0); err != nil {
+				t.Fatalf("failed to set value: %v", err)
+			}
+		}
+		if err := kv.Set(ctx, "kvtest:scan:expired", []byte("value"), time.Millisecond); err != nil {
+			t.Fatalf("failed to set value: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		for prefix, expected := range map[string][]string{
+			"kvtest:scan:":   {"kvtest:scan:a", "kvtest:scan:b"},
+			"kvtest:scan_*:": {"kvtest:scan_*:c"},
+			"kvtest:none:":   nil,
+		} {
+			keys, err := kv.Scan(ctx, prefix)
+			if err != nil {
+				t.Fatalf("failed to scan: %v", err)
+			}
+
+			slices.Sort(keys)
+			if !slices.Equal(expected, keys) {
+				t.Fatalf("expected %v got %v for prefix %q", expected, keys, prefix)
+			}
+		}
+	})
+
+	swapper, ok := kv.(kvstore.Swapper)
+	if !ok {
+		return
+	}
+
+	t.Run("compare and swap", func(t *testing.T) {
+		for _, tc := range []struct {
+			old, value string
+			nilOld     bool
+			swapped    bool
+		}{
+			{nilOld: true, value: "first", swapped: true},
+			{nilOld: true, value: "second", swapped: false},
+			{old: "other", value: "second", swapped: false},
+			{old: "first", value: "second", swapped: true},
+		} {
+			var old []byte
+			if !tc.nilOld {
+				old = []byte(tc.old)
+			}
+
+			swapped, err := swapper.CompareAndSwap(ctx, "kvtest:cas", old, []byte(tc.value), time.Minute)
+			if err != nil {
+				t.Fatalf("failed to compare and swap: %v", err)
+			}
+			if swapped != tc.swapped {
+				t.Fatalf("expected swapped %v got %v for %+v", tc.swapped, swapped, tc)
+			}
+		}
+
+		got, err := kv.Get(ctx, "kvtest:cas")
+		if err != nil {
+			t.Fatalf("failed to get value: %v", err)
+		}
+		if string(got) != "second" {
+			t.Fatalf("expected %q got %q", "second", got)
+		}
+	})
+}
@@ -0,0 +1,391 @@
+package memorykv
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+)
+
+type item struct {
+	key    string
+	value  []byte
+	expiry time.Time
+	elem   *list.Element
+}
+
+// expired returns true if the item has an expiry before now.
+func (i *item) expired(now time.Time) bool {
+	return !i.expiry.IsZero() && now.After(i.expiry)
+}
+
+// size returns the approximate size of the item in bytes.
+func (i *item) size() int { return len(i.key) + len(i.value) }
+
+// removal describes an item removed by the KV itself, to be passed to the
+// registered notify functions once the KV is unlocked.
+type removal struct {
+	key     string
+	value   []byte
+	expired bool
+}
+
+// Stats describes the state and activity of the items limited by a KV.
+type Stats struct {
+	// Items specifies the number of stored items.
+	Items int
+
+	// Bytes specifies the approximate size of the stored items.
+	Bytes int
+
+	// Hits specifies the number of lookups that found an item.
+	Hits uint64
+
+	// Misses specifies the number of lookups that did not find an item.
+	Misses uint64
+
+	// Evictions specifies the number of items removed to satisfy the limits.
+	Evictions uint64
+
+	// Expirations specifies the number of items removed due to expiry.
+	Expirations uint64
+}
+
+// KV defines an in memory key value backend for the kvstore package.
+type KV struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	items  map[string]*item
+	lru    *list.List
+	notify []func(key string, value []byte, expired bool)
+
+	// prefix selects the items that are limited and reported in the stats.
+	// maxItems and maxBytes limit those items when non-zero.
+	prefix   string
+	maxItems int
+	maxBytes int
+	stats    Stats
+}
+
+// New returns a new in memory KV.
+// Parameters:
+// - interval specifies the frequency that expired items are cleared.
+func New(interval time.Duration) *KV {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	kv := &KV{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		items:  make(map[string]*item),
+		lru:    list.New(),
+	}
+
+	go func() {
+		defer close(kv.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case now := <-ticker.C:
+				kv.expire(now)
+			}
+		}
+	}()
+
+	return kv
+}
+
+// Close releases any goroutines allocated by the KV, waiting for any clearing of
+// expired items in progress to finish.
+func (kv *KV) Close() {
+	kv.cancel()
+	<-kv.done
+}
+
+// Notify registers fn to be called with each item the KV removes itself, either
+// because it expired or was evicted to satisfy the limits.
+func (kv *KV) Notify(fn func(key string, value []byte, expired bool)) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.notify = append(kv.notify, fn)
+}
+
+// Limit bounds the number and approximate total size in bytes of the items with
+// keys starting with prefix, evicting the least recently used of them when either
+// is exceeded. Other items are neither limited nor evicted. A limit of zero
+// disables it. The stats report the same items.
+func (kv *KV) Limit(prefix string, maxItems, maxBytes int) {
+	kv.mu.Lock()
+
+	if prefix != kv.prefix {
+		kv.prefix = prefix
+		kv.stats = Stats{}
+		for _, item := range kv.items {
+			if kv.limited(item.key) {
+				kv.stats.Items++
+				kv.stats.Bytes += item.size()
+			}
+		}
+	}
+
+	kv.maxItems = maxItems
+	kv.maxBytes = maxBytes
+	removed := kv.evict()
+	kv.mu.Unlock()
+
+	kv.removed(removed)
+}
+
+// Stats returns the current statistics of the items selected by Limit, or of
+// all items if Limit has not been called.
+func (kv *KV) Stats() Stats {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	return kv.stats
+}
+
+// Get retrieves the unexpired value mapped to by the given key.
+func (kv *KV) Get(ctx context.Context, key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	item, ok := kv.items[key]
+	if !ok || item.expired(time.Now()) {
+		if kv.limited(key) {
+			kv.stats.Misses++
+		}
+		return nil, kvstore.ErrNotFound
+	}
+
+	if kv.limited(key) {
+		kv.stats.Hits++
+	}
+	kv.lru.MoveToFront(item.elem)
+
+	return slices.Clone(item.value), nil
+}
+
+// Set adds or updates the key value pair, expiring it after ttl.
+func (kv *KV) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	kv.mu.Lock()
+	removed := kv.insert(key, slices.Clone(value), expiry(ttl))
+	kv.mu.Unlock()
+
+	kv.removed(removed)
+	return nil
+}
+
+// Delete removes the value mapped to by the given key if it exists.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if item, ok := kv.items[key]; ok {
+		kv.remove(item)
+	}
+	return nil
+}
+
+// Scan returns the unexpired keys starting with the given prefix.
+func (kv *KV) Scan(ctx context.Context, prefix string) ([]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	now := time.Now()
+
+	var keys []string
+	for key, item := range kv.items {
+		if strings.HasPrefix(key, prefix) && !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// CompareAndSwap replaces the value mapped to by the given key with value if it
+// is currently equal to old, expiring it after ttl.
+func (kv *KV) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	kv.mu.Lock()
+
+	item, ok := kv.items[key]
+	if ok && item.expired(time.Now()) {
+		ok = false
+	}
+
+	if (old == nil && ok) || (old != nil && (!ok || !bytes.Equal(item.value, old))) {
+		kv.mu.Unlock()
+		return false, nil
+	}
+
+	removed := kv.insert(key, slices.Clone(value), expiry(ttl))
+	kv.mu.Unlock()
+
+	kv.removed(removed)
+	return true, nil
+}
+
+// entry is the serialised form of an item in a snapshot.
+type entry struct {
+	Key    string
+	Value  []byte
+	Expiry time.Time
+}
+
+// Snapshot writes all unexpired items to w, to be restored with Restore.
+func (kv *KV) Snapshot(w io.Writer) error {
+	now := time.Now()
+
+	var entries []entry
+	kv.mu.Lock()
+	for _, item := range kv.items {
+		if !item.expired(now) {
+			entries = append(entries, entry{Key: item.key, Value: item.value, Expiry: item.expiry})
+		}
+	}
+	kv.mu.Unlock()
+
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Restore adds the unexpired items from a snapshot written by Snapshot,
+// evicting items if the limits are exceeded.
+func (kv *KV) Restore(r io.Reader) error {
+	var entries []entry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var removed []removal
+	kv.mu.Lock()
+	for _, e := range entries {
+		if e.Expiry.IsZero() || now.Before(e.Expiry) {
+			removed = append(removed, kv.insert(e.Key, e.Value, e.Expiry)...)
+		}
+	}
+	kv.mu.Unlock()
+
+	kv.removed(removed)
+	return nil
+}
+
+// limited returns true if the item with the given key is limited and reported
+// in the stats.
+func (kv *KV) limited(key string) bool { return strings.HasPrefix(key, kv.prefix) }
+
+// insert stores the item, marking it as recently used, and evicts items if the
+// limits are exceeded, returning the evicted items. kv.mu must be held.
+func (kv *KV) insert(key string, value []byte, expiry time.Time) []removal {
+	if item, ok := kv.items[key]; ok {
+		kv.remove(item)
+	}
+
+	item := &item{key: key, value: value, expiry: expiry}
+	item.elem = kv.lru.PushFront(item)
+	kv.items[key] = item
+
+	if kv.limited(key) {
+		kv.stats.Items++
+		kv.stats.Bytes += item.size()
+	}
+
+	return kv.evict()
+}
+
+// remove removes the item from the KV. kv.mu must be held.
+func (kv *KV) remove(item *item) {
+	delete(kv.items, item.key)
+	kv.lru.Remove(item.elem)
+
+	if kv.limited(item.key) {
+		kv.stats.Items--
+		kv.stats.Bytes -= item.size()
+	}
+}
+
+// evict removes the least recently used limited items until the limits are
+// satisfied, returning the removed items. kv.mu must be held.
+func (kv *KV) evict() []removal {
+	var removed []removal
+
+	elem := kv.lru.Back()
+	for elem != nil &&
+		((kv.maxItems > 0 && kv.stats.Items > kv.maxItems) || (kv.maxBytes > 0 && kv.stats.Bytes > kv.maxBytes)) {
+		item := elem.Value.(*item)
+		elem = elem.Prev()
+
+		if !kv.limited(item.key) {
+			continue
+		}
+
+		kv.remove(item)
+		kv.stats.Evictions++
+		removed = append(removed, removal{key: item.key, value: item.value})
+	}
+
+	return removed
+}
+
+// expire removes all items that expired before now, notifying the registered
+// functions once the KV is unlocked.
+func (kv *KV) expire(now time.Time) {
+	var removed []removal
+
+	kv.mu.Lock()
+	for _, item := range kv.items {
+		if item.expired(now) {
+			kv.remove(item)
+			if kv.limited(item.key) {
+				kv.stats.Expirations++
+			}
+			removed = append(removed, removal{key: item.key, value: item.value, expired: true})
+		}
+	}
+	kv.mu.Unlock()
+
+	kv.removed(removed)
+}
+
+// removed passes the removed items to the registered notify functions.
+// kv.mu must not be held.
+func (kv *KV) removed(removed []removal) {
+	if len(removed) == 0 {
+		return
+	}
+
+	kv.mu.Lock()
+	notify := slices.Clone(kv.notify)
+	kv.mu.Unlock()
+
+	for _, r := range removed {
+		for _, fn := range notify {
+			fn(r.key, r.value, r.expired)
+		}
+	}
+}
+
+// expiry returns the expiry datetime for ttl, or the zero time for no expiry.
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
@@ -0,0 +1,16 @@
+package memorykv_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions/kvstore/kvtest"
+	"github.com/dimmerz92/sittella/sessions/kvstore/memorykv"
+)
+
+func TestMemoryKV(t *testing.T) {
+	kv := memorykv.New(time.Minute)
+	defer kv.Close()
+
+	kvtest.Run(t, kv)
+}
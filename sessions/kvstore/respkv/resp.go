@@ -0,0 +1,397 @@
+package respkv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+)
+
+var ErrClosed = errors.New("respkv: client closed")
+
+// Error defines an error reply returned by the server.
+type Error string
+
+func (e Error) Error() string { return "respkv: " + string(e) }
+
+// KV defines a key value backend for the kvstore package that speaks the RESP
+// (Redis serialization) protocol, for use with Redis compatible servers.
+type KV struct {
+	addr     string
+	password string
+	db       int
+	dialer   net.Dialer
+
+	mu     sync.Mutex
+	idle   []*conn
+	size   int
+	closed bool
+}
+
+// New returns a new RESP KV. Connections are opened on demand.
+// Parameters:
+// - addr specifies the TCP address of the server (e.g. "localhost:6379").
+// - password specifies the password sent with AUTH; empty skips authentication.
+// - db specifies the database selected with SELECT; zero uses the default database.
+// - poolSize specifies the maximum number of idle connections kept open.
+func New(addr, password string, db, poolSize int) *KV {
+	return &KV{
+		addr:     addr,
+		password: password,
+		db:       db,
+		dialer:   net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second},
+		size:     max(poolSize, 1),
+	}
+}
+
+// Close closes the idle connections and prevents new ones from being opened.
+func (kv *KV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.closed = true
+	for _, c := range kv.idle {
+		c.Close()
+	}
+	kv.idle = nil
+
+	return nil
+}
+
+// Get retrieves the unexpired value mapped to by the given key.
+func (kv *KV) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := kv.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, kvstore.ErrNotFound
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("respkv: unexpected GET reply %T", reply)
+	}
+
+	return value, nil
+}
+
+// Set adds or updates the key value pair, expiring it after ttl.
+func (kv *KV) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := kv.do(ctx, set(key, value, ttl)...)
+	return err
+}
+
+// Delete removes the value mapped to by the given key if it exists.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	_, err := kv.do(ctx, "DEL", key)
+	return err
+}
+
+// Scan returns the unexpired keys starting with the given prefix.
+func (kv *KV) Scan(ctx context.Context, prefix string) ([]string, error) {
+	pattern := escapeGlob(prefix) + "*"
+	seen := make(map[string]struct{})
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := kv.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("respkv: unexpected SCAN reply %v", reply)
+		}
+
+		next, _ := page[0].([]byte)
+		items, _ := page[1].([]any)
+		for _, item := range items {
+			key, _ := item.([]byte)
+			if _, ok := seen[string(key)]; !ok {
+				seen[string(key)] = struct{}{}
+				keys = append(keys, string(key))
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// CompareAndSwap replaces the value mapped to by the given key with value if it
+// is currently equal to old, expiring it after ttl. The swap is performed in a
+// WATCH/MULTI/EXEC transaction.
+func (kv *KV) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	var swapped bool
+
+	err := kv.withConn(ctx, func(c *conn) error {
+		// the connection is left watching or in a transaction if any command fails.
+		c.dirty = true
+
+		if _, err := c.do("WATCH", key); err != nil {
+			return err
+		}
+
+		current, err := c.do("GET", key)
+		if err != nil {
+			return err
+		}
+
+		stored, _ := current.([]byte)
+		if (old == nil) != (current == nil) || !bytes.Equal(stored, old) {
+			_, err := c.do("UNWATCH")
+			c.dirty = err != nil
+			return err
+		}
+
+		if _, err := c.do("MULTI"); err != nil {
+			return err
+		}
+		if _, err := c.do(set(key, value, ttl)...); err != nil {
+			return err
+		}
+
+		reply, err := c.do("EXEC")
+		if err != nil {
+			return err
+		}
+
+		c.dirty = false
+		swapped = reply != nil
+		return nil
+	})
+
+	return swapped, err
+}
+
+// do sends the command on a pooled connection and returns the reply.
+func (kv *KV) do(ctx context.Context, args ...any) (any, error) {
+	var reply any
+	err := kv.withConn(ctx, func(c *conn) error {
+		var err error
+		reply, err = c.do(args...)
+		return err
+	})
+	return reply, err
+}
+
+// withConn calls fn with a pooled connection, honouring the deadline and
+// cancellation of ctx. Connections are discarded after network or protocol
+// errors, or if fn leaves them dirty.
+func (kv *KV) withConn(ctx context.Context, fn func(c *conn) error) error {
+	c, err := kv.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	c.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() { c.SetDeadline(time.Unix(1, 0)) })
+
+	err = fn(c)
+
+	var reply Error
+	if !stop() || c.dirty || (err != nil && !errors.As(err, &reply)) {
+		c.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	kv.put(c)
+	return err
+}
+
+// get returns an idle connection or dials a new one.
+func (kv *KV) get(ctx context.Context) (*conn, error) {
+	kv.mu.Lock()
+	if kv.closed {
+		kv.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(kv.idle); n > 0 {
+		c := kv.idle[n-1]
+		kv.idle = kv.idle[:n-1]
+		kv.mu.Unlock()
+		return c, nil
+	}
+	kv.mu.Unlock()
+
+	nc, err := kv.dialer.DialContext(ctx, "tcp", kv.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	deadline, _ := ctx.Deadline()
+	c.SetDeadline(deadline)
+
+	if kv.password != "" {
+		if _, err := c.do("AUTH", kv.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if kv.db != 0 {
+		if _, err := c.do("SELECT", kv.db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// put returns the connection to the idle pool, closing it if the pool is full.
+func (kv *KV) put(c *conn) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.closed || len(kv.idle) >= kv.size {
+		c.Close()
+		return
+	}
+	kv.idle = append(kv.idle, c)
+}
+
+// conn defines a buffered connection to the server.
+type conn struct {
+	net.Conn
+	r     *bufio.Reader
+	w     *bufio.Writer
+	dirty bool
+}
+
+// do writes the command and reads its reply. Error replies are returned as errors.
+func (c *conn) do(args ...any) (any, error) {
+	if err := c.write(args...); err != nil {
+		return nil, err
+	}
+
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+
+	return reply, nil
+}
+
+// write writes the command as an array of bulk strings.
+func (c *conn) write(args ...any) error {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+
+	for _, arg := range args {
+		var b []byte
+		switch arg := arg.(type) {
+		case string:
+			b = []byte(arg)
+		case []byte:
+			b = arg
+		case int:
+			b = strconv.AppendInt(nil, int64(arg), 10)
+		case int64:
+			b = strconv.AppendInt(nil, arg, 10)
+		default:
+			return fmt.Errorf("respkv: unsupported argument type %T", arg)
+		}
+
+		c.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+		c.w.Write(b)
+		c.w.WriteString("\r\n")
+	}
+
+	return c.w.Flush()
+}
+
+// read reads a reply, returning a string for simple strings, an Error for error
+// replies, an int64 for integers, a []byte for bulk strings, an []any for arrays
+// and nil for null replies.
+func (c *conn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("respkv: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return Error(line[1:]), nil
+
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("respkv: unknown reply type %q", line[0])
+	}
+}
+
+// set returns the SET command arguments for the key value pair and ttl.
+func set(key string, value []byte, ttl time.Duration) []any {
+	if ttl <= 0 {
+		return []any{"SET", key, value}
+	}
+	return []any{"SET", key, value, "PX", max(ttl.Milliseconds(), 1)}
+}
+
+// escapeGlob escapes the glob special characters in s.
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,309 @@
+package respkv_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions/kvstore/kvtest"
+	"github.com/dimmerz92/sittella/sessions/kvstore/respkv"
+)
+
+// server is an in-process stand-in for a Redis compatible server, supporting
+// only the commands used by respkv.
+type server struct {
+	password string
+
+	mu       sync.Mutex
+	items    map[string]item
+	versions map[string]uint64
+	version  uint64
+}
+
+type item struct {
+	value  []byte
+	expiry time.Time
+}
+
+// conn holds the per-connection server state.
+type conn struct {
+	authed  bool
+	watched map[string]uint64
+	multi   bool
+	queued  [][]string
+}
+
+func newServer(t *testing.T, password string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &server{
+		password: password,
+		items:    make(map[string]item),
+		versions: make(map[string]uint64),
+	}
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	state := &conn{authed: s.password == ""}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		if _, err := io.WriteString(c, s.handle(state, args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) handle(c *conn, args []string) string {
+	cmd := strings.ToUpper(args[0])
+
+	if cmd == "AUTH" {
+		if len(args) != 2 || args[1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		c.authed = true
+		return "+OK\r\n"
+	}
+	if !c.authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd {
+	case "WATCH":
+		if c.watched == nil {
+			c.watched = make(map[string]uint64)
+		}
+		for _, key := range args[1:] {
+			c.watched[key] = s.versions[key]
+		}
+		return "+OK\r\n"
+
+	case "UNWATCH":
+		c.watched = nil
+		return "+OK\r\n"
+
+	case "MULTI":
+		c.multi = true
+		return "+OK\r\n"
+
+	case "EXEC":
+		queued, watched := c.queued, c.watched
+		c.multi, c.queued, c.watched = false, nil, nil
+
+		for key, version := range watched {
+			if s.versions[key] != version {
+				return "*-1\r\n"
+			}
+		}
+
+		reply := "*" + strconv.Itoa(len(queued)) + "\r\n"
+		for _, args := range queued {
+			reply += s.exec(args)
+		}
+		return reply
+	}
+
+	if c.multi {
+		c.queued = append(c.queued, args)
+		return "+QUEUED\r\n"
+	}
+
+	return s.exec(args)
+}
+
+// exec executes a data command. s.mu must be held.
+func (s *server) exec(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+
+	case "GET":
+		item, ok := s.items[args[1]]
+		if !ok || (!item.expiry.IsZero() && time.Now().After(item.expiry)) {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(item.value)) + "\r\n" + string(item.value) + "\r\n"
+
+	case "SET":
+		item := item{value: []byte(args[2])}
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			item.expiry = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		s.items[args[1]] = item
+		s.version++
+		s.versions[args[1]] = s.version
+		return "+OK\r\n"
+
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.items[key]; ok {
+				delete(s.items, key)
+				s.version++
+				s.versions[key] = s.version
+				n++
+			}
+		}
+		return ":" + strconv.Itoa(n) + "\r\n"
+
+	case "SCAN":
+		var keys []string
+		for key, item := range s.items {
+			if (item.expiry.IsZero() || time.Now().Before(item.expiry)) && match(args[3], key) {
+				keys = append(keys, key)
+			}
+		}
+
+		reply := "*2\r\n$1\r\n0\r\n*" + strconv.Itoa(len(keys)) + "\r\n"
+		for _, key := range keys {
+			reply += "$" + strconv.Itoa(len(key)) + "\r\n" + key + "\r\n"
+		}
+		return reply
+
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// match reports whether s matches the glob pattern, supporting *, ? and escapes.
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array got %q", line)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func TestRESPKV(t *testing.T) {
+	kv := respkv.New(newServer(t, ""), "", 0, 4)
+	defer kv.Close()
+
+	kvtest.Run(t, kv)
+}
+
+func TestRESPKVAuth(t *testing.T) {
+	addr := newServer(t, "secret")
+
+	kv := respkv.New(addr, "secret", 1, 4)
+	defer kv.Close()
+
+	kvtest.Run(t, kv)
+
+	t.Run("wrong password", func(t *testing.T) {
+		kv := respkv.New(addr, "wrong", 0, 4)
+		defer kv.Close()
+
+		var reply respkv.Error
+		if _, err := kv.Get(context.Background(), "key"); !errors.As(err, &reply) {
+			t.Fatalf("expected error reply got %v", err)
+		}
+	})
+}
+
+func TestRESPKVClosed(t *testing.T) {
+	kv := respkv.New(newServer(t, ""), "", 0, 4)
+	kv.Close()
+
+	if _, err := kv.Get(context.Background(), "key"); err != respkv.ErrClosed {
+		t.Fatalf("expected %v got %v", respkv.ErrClosed, err)
+	}
+}
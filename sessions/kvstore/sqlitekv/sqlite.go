@@ -0,0 +1,203 @@
+package sqlitekv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+)
+
+// Schema creates the kv table and expiry index if they do not exist.
+// New applies it automatically; it is exported for use in migrations.
+const Schema = `
+CREATE TABLE IF NOT EXISTS kv (
+	key    TEXT PRIMARY KEY,
+	value  BLOB NOT NULL,
+	expiry INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS kv_expiry_idx ON kv (expiry) WHERE expiry != 0;
+`
+
+// KV defines an SQLite backed key value backend for the kvstore package.
+type KV struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	db     *database.Database
+
+	mu     sync.Mutex
+	notify []func(key string, value []byte, expired bool)
+}
+
+// New returns a new SQLite backed KV, creating the kv table if it does not exist.
+// Parameters:
+// - db specifies the database the kv table is stored in.
+// - interval specifies the frequency that expired items are cleared.
+func New(db *database.Database, interval time.Duration) (*KV, error) {
+	if db == nil {
+		return nil, errors.New("sqlitekv.New: db required")
+	}
+
+	if _, err := db.Exec(Schema); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	kv := &KV{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		db:     db,
+	}
+
+	go func() {
+		defer close(kv.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				if err := kv.expire(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("sqlitekv: failed to clear expired items", "error", err)
+				}
+			}
+		}
+	}()
+
+	return kv, nil
+}
+
+// Close releases any goroutines allocated by the KV, waiting for any clearing of
+// expired items in progress to finish. The database is not closed.
+func (kv *KV) Close() {
+	kv.cancel()
+	<-kv.done
+}
+
+// Notify registers fn to be called with each item the KV removes because it
+// expired.
+func (kv *KV) Notify(fn func(key string, value []byte, expired bool)) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.notify = append(kv.notify, fn)
+}
+
+// expire deletes the expired items. If any notify functions are registered, the
+// deleted items are returned from the delete and passed to them.
+func (kv *KV) expire(ctx context.Context) error {
+	kv.mu.Lock()
+	notify := slices.Clone(kv.notify)
+	kv.mu.Unlock()
+
+	now := time.Now().UnixNano()
+
+	if len(notify) == 0 {
+		_, err := kv.db.ExecContext(ctx, "DELETE FROM kv WHERE expiry != 0 AND expiry <= ?", now)
+		return err
+	}
+
+	var rows []struct {
+		Key   string `db:"key"`
+		Value []byte `db:"value"`
+	}
+
+	if err := kv.db.Writer().SelectContext(ctx, &rows, "DELETE FROM kv WHERE expiry != 0 AND expiry <= ? RETURNING key, value", now); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		for _, fn := range notify {
+			fn(row.Key, row.Value, true)
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves the unexpired value mapped to by the given key.
+func (kv *KV) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := kv.db.GetContext(ctx, &value, `
+		SELECT value FROM kv WHERE key = ? AND (expiry = 0 OR expiry > ?)`,
+		key, time.Now().UnixNano(),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, kvstore.ErrNotFound
+	}
+
+	return value, err
+}
+
+// Set adds or updates the key value pair, expiring it after ttl.
+func (kv *KV) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := kv.db.ExecContext(ctx, `
+		INSERT INTO kv (key, value, expiry) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expiry = excluded.expiry`,
+		key, value, expiry(ttl),
+	)
+	return err
+}
+
+// Delete removes the value mapped to by the given key if it exists.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	_, err := kv.db.ExecContext(ctx, "DELETE FROM kv WHERE key = ?", key)
+	return err
+}
+
+// Scan returns the unexpired keys starting with the given prefix.
+func (kv *KV) Scan(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := kv.db.SelectContext(ctx, &keys, `
+		SELECT key FROM kv WHERE substr(key, 1, length(?)) = ? AND (expiry = 0 OR expiry > ?)`,
+		prefix, prefix, time.Now().UnixNano(),
+	)
+	return keys, err
+}
+
+// CompareAndSwap replaces the value mapped to by the given key with value if it
+// is currently equal to old, expiring it after ttl.
+func (kv *KV) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	now := time.Now().UnixNano()
+
+	var result sql.Result
+	var err error
+	if old == nil {
+		result, err = kv.db.ExecContext(ctx, `
+			INSERT INTO kv (key, value, expiry) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value, expiry = excluded.expiry
+			WHERE kv.expiry != 0 AND kv.expiry <= ?`,
+			key, value, expiry(ttl), now,
+		)
+	} else {
+		result, err = kv.db.ExecContext(ctx, `
+			UPDATE kv SET value = ?, expiry = ?
+			WHERE key = ? AND value = ? AND (expiry = 0 OR expiry > ?)`,
+			value, expiry(ttl), key, old, now,
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// expiry returns the expiry in unix nanoseconds for ttl, or zero for no expiry.
+func expiry(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
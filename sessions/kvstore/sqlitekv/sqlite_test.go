@@ -0,0 +1,24 @@
+package sqlitekv_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+	"github.com/dimmerz92/sittella/sessions/kvstore/kvtest"
+	"github.com/dimmerz92/sittella/sessions/kvstore/sqlitekv"
+)
+
+func TestSQLiteKV(t *testing.T) {
+	db := sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3") + "?_pragma=busy_timeout(5000)")
+	defer db.Close()
+
+	kv, err := sqlitekv.New(db, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create kv: %v", err)
+	}
+	defer kv.Close()
+
+	kvtest.Run(t, kv)
+}
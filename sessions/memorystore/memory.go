@@ -1,194 +1,120 @@
 package memorystore
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
-	"net/http"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/dimmerz92/sittella/sessions"
-	"github.com/google/uuid"
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+	"github.com/dimmerz92/sittella/sessions/kvstore/memorykv"
 )
 
-type session struct {
-	id     string
-	expiry time.Time
-	data   map[string][]byte
+// Stats describes the state and activity of a Store.
+type Stats struct {
+	// Sessions specifies the number of stored sessions.
+	Sessions int
+
+	// Bytes specifies the approximate size of the stored sessions.
+	Bytes int
+
+	// Hits specifies the number of session lookups that found a session.
+	Hits uint64
+
+	// Misses specifies the number of session lookups that did not find a session.
+	Misses uint64
+
+	// Evictions specifies the number of sessions removed to satisfy the limits.
+	Evictions uint64
+
+	// Expirations specifies the number of sessions removed due to expiry.
+	Expirations uint64
 }
 
 // Store defines an in memory session store to retrieve or generate request scoped sessions.
+// It is a kvstore.Store backed by a memorykv.KV, with limits, statistics and
+// optional persistence.
 type Store struct {
+	*kvstore.Store
+	kv *memorykv.KV
+
+	// ctx, cancel and wg stop the snapshot goroutine when persistence is enabled.
+	ctx    context.Context
 	cancel context.CancelFunc
-	cookie sessions.CookieOpts
-	data   sync.Map
-	ttl    time.Duration
+	wg     sync.WaitGroup
+
+	// path specifies the snapshot file used when persistence is enabled.
+	path       string
+	snapshotMu sync.Mutex
 }
 
 // New returns a new in memory session Store.
 // Parameters:
 // - interval specifies the frequency that expired sessions are cleared.
-// - ttl specifies the time to live for individual sessions.
-// - opts specify the cookie options to be used.
-func New(interval, ttl time.Duration, opts sessions.CookieOpts) *Store {
+// - idle specifies how long a session lives without activity; it is renewed each time the session is saved.
+// - cookie specifies the cookie options to be used.
+// - opts specify optional store settings such as the value codec, absolute lifetime and lifecycle hooks.
+func New(interval, idle time.Duration, cookie sessions.CookieOpts, opts ...sessions.Option) *Store {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	kv := memorykv.New(interval)
 	store := &Store{
+		Store:  kvstore.New(kv, "", idle, cookie, opts...),
+		kv:     kv,
+		ctx:    ctx,
 		cancel: cancel,
-		cookie: opts,
-		data:   sync.Map{},
-		ttl:    ttl,
 	}
 
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-
-			case <-ticker.C:
-				store.data.Range(func(key, value any) bool {
-					if time.Now().After(value.(session).expiry) {
-						store.data.Delete(key)
-					}
-					return true
-				})
-			}
-		}
-	}()
+	// only the sessions are limited, not the user index.
+	kv.Limit(store.SessionPrefix(), 0, 0)
 
 	return store
 }
 
-// Session retrieves or generates a new session for the current request.
-func (s *Store) Session(w http.ResponseWriter, r *http.Request) sessions.Session {
-	sess := &Session{
-		req:   r,
-		res:   w,
-		store: s,
-		mu:    &sync.RWMutex{},
-	}
-
-	cookie, _ := r.Cookie(s.cookie.Name)
-	if cookie != nil {
-		data, ok := s.data.Load(cookie.Value)
-		if ok && time.Now().Before(data.(session).expiry) {
-			sess.data = data.(session)
-			return sess
-		}
-	}
-
-	sess.data = session{
-		id:     uuid.NewString(),
-		expiry: time.Now().Add(s.ttl),
-		data:   make(map[string][]byte),
-	}
-
-	http.SetCookie(w, s.cookie.ToCookie(sess.data.id, s.ttl))
-
-	return sess
+// NewPersistent returns a new in memory session Store that snapshots its
+// sessions to a file so they survive restarts. Unexpired sessions are restored
+// from the file before NewPersistent returns.
+// Parameters:
+// - path specifies the snapshot file, which is written atomically.
+// - snapshot specifies the frequency of snapshots; zero only snapshots when the store is stopped.
+// - interval, idle, cookie and opts are as for New.
+func NewPersistent(path string, snapshot, interval, idle time.Duration, cookie sessions.CookieOpts, opts ...sessions.Option) *Store {
+	store := New(interval, idle, cookie, opts...)
+	store.persist(path, snapshot)
+	return store
 }
 
 // Stop releases any goroutines and resources allocated by the Store.
-func (s *Store) Stop() { s.cancel() }
-
-// Session defines an in memory request scoped session.
-type Session struct {
-	req     *http.Request
-	res     http.ResponseWriter
-	store   *Store
-	data    session
-	mu      *sync.RWMutex
-	ttl     time.Duration
-	changed bool
-}
-
-// Set adds or updates the key value pair to the session.
-func (s *Session) Set(key string, value any) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
-		return err
+// If the store was created with NewPersistent, a final snapshot of the sessions
+// is written.
+func (s *Store) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.kv.Close()
+
+	if err := s.Snapshot(); err != nil {
+		slog.Error("memorystore: failed to snapshot sessions", "error", err)
 	}
-
-	s.data.data[key] = buf.Bytes()
-
-	s.changed = true
-
-	return nil
 }
 
-// Get retrieves and decodes the value mapped to by the given key if it exists.
-func (s *Session) Get(key string, dest any) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	data, ok := s.data.data[key]
-	if !ok {
-		return sessions.ErrValueNotFound
-	}
-
-	return gob.NewDecoder(bytes.NewBuffer(data)).Decode(dest)
-}
-
-// Delete removes the value mapped to by the given key from the session.
-func (s *Session) Delete(key string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	delete(s.data.data, key)
-
-	s.changed = true
-}
-
-// Clear removes all key value pairs from the session.
-func (s *Session) Clear() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.data.data = make(map[string][]byte)
-
-	s.changed = true
+// Limit bounds the number of sessions and their approximate total size in
+// bytes, evicting the least recently used sessions when either is exceeded and
+// calling the OnDestroy hook for each.
+// A limit of zero disables it.
+func (s *Store) Limit(maxSessions, maxBytes int) {
+	s.kv.Limit(s.SessionPrefix(), maxSessions, maxBytes)
 }
 
-// Expiry returns the expiry datetime for the session.
-func (s *Session) Expiry() time.Time {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	return s.data.expiry
-}
-
-// Extend updates the session expiry.
-func (s *Session) Extend(ttl time.Duration) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if ttl > 0 {
-		s.ttl = ttl
-		s.changed = true
-	}
-}
-
-// Save persists any changes made to the session in the store.
-func (s *Session) Save() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.changed {
-		if s.ttl > 0 {
-			s.data.expiry = time.Now().Add(s.ttl)
-			http.SetCookie(s.res, s.store.cookie.ToCookie(s.data.id, s.ttl))
-		}
-
-		s.store.data.Store(s.data.id, s.data)
+// Stats returns the current store statistics.
+func (s *Store) Stats() Stats {
+	stats := s.kv.Stats()
+	return Stats{
+		Sessions:    stats.Items,
+		Bytes:       stats.Bytes,
+		Hits:        stats.Hits,
+		Misses:      stats.Misses,
+		Evictions:   stats.Evictions,
+		Expirations: stats.Expirations,
 	}
-
-	return nil
 }
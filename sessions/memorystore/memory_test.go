@@ -3,12 +3,15 @@ package memorystore_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/dimmerz92/sittella/sessions"
 	"github.com/dimmerz92/sittella/sessions/memorystore"
+	"github.com/dimmerz92/sittella/sessions/sessiontest"
 )
 
 func newRequest(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
@@ -20,98 +23,235 @@ func newRequest(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request)
 	return w, r
 }
 
-type testType struct {
-	Data int
-}
-
-var key = "test-key"
+var key = sessiontest.Key
 
-var expected = testType{42}
+var expected = sessiontest.Expected
 
 func TestMemoryStore(t *testing.T) {
-	store := memorystore.New(100*time.Millisecond, 90*time.Millisecond, sessions.CookieOpts{Name: "test"})
-	defer store.Stop()
+	sessiontest.Run(t, func(t *testing.T, idle time.Duration, opts ...sessions.Option) sessions.Store {
+		return memorystore.New(time.Minute, idle, sessions.CookieOpts{Name: "test"}, opts...)
+	})
+}
 
-	w, r := newRequest(nil)
+func TestMemoryStorePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.gob")
 
-	t.Run("set", func(t *testing.T) {
-		session := store.Session(w, r)
+	store := memorystore.NewPersistent(path, 10*time.Millisecond, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
 
-		if err := session.Set(key, expected); err != nil {
-			t.Fatalf("failed to set data: %v", err)
-		}
+	w, r := newRequest(nil)
+	session := store.Session(w, r)
 
-		if err := session.Save(); err != nil {
-			t.Fatalf("failed to save session: %v", err)
-		}
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
 
-		if len(w.Result().Cookies()) != 1 && w.Result().Cookies()[0].Name != "test" {
-			t.Fatal("failed to set cookie")
-		}
-	})
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
 
-	t.Run("get", func(t *testing.T) {
-		w, r = newRequest(w.Result().Cookies()[0])
-		session := store.Session(w, r)
+	cookie := w.Result().Cookies()[0]
+
+	get := func(store *memorystore.Store) {
+		t.Helper()
 
-		var out testType
-		if err := session.Get(key, &out); err != nil {
+		var out sessiontest.Value
+		if err := store.Session(newRequest(cookie)).Get(key, &out); err != nil {
 			t.Fatalf("failed to get data: %v", err)
 		}
 
 		if !reflect.DeepEqual(expected, out) {
 			t.Fatalf("expected %#v got %#v", expected, out)
 		}
-	})
+	}
 
-	t.Run("delete", func(t *testing.T) {
-		w, r = newRequest(r.Cookies()[0])
-		session := store.Session(w, r)
+	t.Run("interval", func(t *testing.T) {
+		time.Sleep(50 * time.Millisecond)
 
-		session.Delete(key)
+		// the snapshot is written without stopping the store.
+		restored := memorystore.NewPersistent(path, 0, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+		defer restored.Stop()
 
-		if err := session.Save(); err != nil {
-			t.Fatalf("failed to save session: %v", err)
+		get(restored)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		store.Stop()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected snapshot file: %v", err)
 		}
 
-		var out testType
-		if err := session.Get(key, &out); err != sessions.ErrValueNotFound {
-			t.Fatalf("expected not found, got %v", err)
+		// no snapshots are written once the store is stopped.
+		time.Sleep(50 * time.Millisecond)
+		if after, err := os.Stat(path); err != nil || !after.ModTime().Equal(info.ModTime()) {
+			t.Fatal("expected snapshots to stop with the store")
 		}
+
+		restarted := memorystore.NewPersistent(path, time.Minute, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+		defer restarted.Stop()
+
+		get(restarted)
 	})
+}
+
+func TestMemoryStoreLimits(t *testing.T) {
+	store := memorystore.New(time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	defer store.Stop()
 
-	t.Run("extended & expired", func(t *testing.T) {
-		w, r = newRequest(r.Cookies()[0])
+	store.Limit(2, 0)
+
+	create := func() *http.Cookie {
+		w, r := newRequest(nil)
 		session := store.Session(w, r)
 
 		if err := session.Set(key, expected); err != nil {
 			t.Fatalf("failed to set data: %v", err)
 		}
 
-		session.Extend(180 * time.Millisecond)
-
 		if err := session.Save(); err != nil {
 			t.Fatalf("failed to save session: %v", err)
 		}
 
-		time.Sleep(110 * time.Millisecond)
+		return w.Result().Cookies()[0]
+	}
+
+	first, second := create(), create()
 
-		w, r = newRequest(r.Cookies()[0])
-		session = store.Session(w, r)
+	var out sessiontest.Value
+	if err := store.Session(newRequest(first)).Get(key, &out); err != nil {
+		t.Fatalf("failed to get data: %v", err)
+	}
 
-		var out testType
-		if err := session.Get(key, &out); err != nil {
-			t.Fatalf("failed to get data: %v", err)
+	third := create()
+
+	t.Run("lru eviction", func(t *testing.T) {
+		for _, cookie := range []*http.Cookie{first, third} {
+			if err := store.Session(newRequest(cookie)).Get(key, &out); err != nil {
+				t.Fatalf("failed to get data: %v", err)
+			}
+		}
+
+		if err := store.Session(newRequest(second)).Get(key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected least recently used session to be evicted, got %v", err)
 		}
+	})
 
-		time.Sleep(90 * time.Millisecond)
+	t.Run("stats", func(t *testing.T) {
+		stats := store.Stats()
+		if stats.Sessions != 2 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 1 || stats.Bytes == 0 {
+			t.Fatalf("unexpected stats %#v", stats)
+		}
+	})
 
-		w, r = newRequest(r.Cookies()[0])
-		session = store.Session(w, r)
+	t.Run("byte limit", func(t *testing.T) {
+		store.Limit(0, store.Stats().Bytes-1)
 
-		var out2 testType
-		if err := session.Get(key, &out2); err != sessions.ErrValueNotFound {
-			t.Fatalf("expected not found, got %v", err)
+		if stats := store.Stats(); stats.Sessions != 1 || stats.Evictions != 2 {
+			t.Fatalf("unexpected stats %#v", stats)
 		}
 	})
 }
+
+func TestMemoryStoreExpirations(t *testing.T) {
+	store := memorystore.New(10*time.Millisecond, 50*time.Millisecond, sessions.CookieOpts{Name: "test"})
+	defer store.Stop()
+
+	w, r := newRequest(nil)
+	session := store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if stats := store.Stats(); stats.Sessions != 0 || stats.Expirations != 1 || stats.Bytes != 0 {
+		t.Fatalf("unexpected stats %#v", stats)
+	}
+}
+
+func TestMemoryStoreHooks(t *testing.T) {
+	var recorder sessiontest.HookRecorder
+	store := memorystore.New(20*time.Millisecond, 100*time.Millisecond, sessions.CookieOpts{Name: "test"},
+		sessions.WithHooks(recorder.Hooks()))
+	defer store.Stop()
+
+	w, r := newRequest(nil)
+	session := store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if err := session.Regenerate(); err != nil {
+		t.Fatalf("failed to regenerate session: %v", err)
+	}
+	if err := session.Destroy(); err != nil {
+		t.Fatalf("failed to destroy session: %v", err)
+	}
+
+	w, r = newRequest(nil)
+	session = store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	events := []string{"create", "regenerate", "destroy", "create", "expire"}
+	if got := recorder.Events(); !reflect.DeepEqual(events, got) {
+		t.Fatalf("expected %v got %v", events, got)
+	}
+}
+
+func TestMemoryStoreEvictionHooks(t *testing.T) {
+	var recorder sessiontest.HookRecorder
+	hooks := recorder.Hooks()
+
+	var store *memorystore.Store
+	destroy := hooks.OnDestroy
+	hooks.OnDestroy = func(id string, data sessions.Snapshot) {
+		// hooks run outside the store lock, so they may use the store.
+		store.Stats()
+		destroy(id, data)
+	}
+
+	store = memorystore.New(time.Minute, time.Minute, sessions.CookieOpts{Name: "test"}, sessions.WithHooks(hooks))
+	defer store.Stop()
+
+	store.Limit(2, 0)
+
+	for range 3 {
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(key, expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+	}
+
+	store.Limit(1, 0)
+
+	events := []string{"create", "create", "destroy", "create", "destroy"}
+	if got := recorder.Events(); !reflect.DeepEqual(events, got) {
+		t.Fatalf("expected %v got %v", events, got)
+	}
+
+	if stats := store.Stats(); stats.Evictions != 2 || stats.Sessions != 1 {
+		t.Fatalf("unexpected stats %#v", stats)
+	}
+}
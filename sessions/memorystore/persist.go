@@ -0,0 +1,92 @@
+package memorystore
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persist restores the sessions from the snapshot file if it exists, then
+// snapshots them to it every interval until the Store is stopped.
+func (s *Store) persist(path string, interval time.Duration) {
+	s.path = path
+
+	if err := s.restore(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("memorystore: failed to restore sessions", "error", err)
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+
+			case <-ticker.C:
+				if err := s.Snapshot(); err != nil {
+					slog.Error("memorystore: failed to snapshot sessions", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Snapshot atomically writes all unexpired sessions to the persistence file.
+// It does nothing if the store was not created with NewPersistent.
+func (s *Store) Snapshot() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := s.kv.Snapshot(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path)
+}
+
+// restore loads the unexpired sessions from the snapshot file at path.
+func (s *Store) restore(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.kv.Restore(file)
+}
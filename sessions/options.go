@@ -0,0 +1,55 @@
+package sessions
+
+import "time"
+
+// Options specifies the optional settings shared by session stores.
+type Options struct {
+	// Codec specifies the codec used to encode session values.
+	// Defaults to GobCodec.
+	Codec Codec
+
+	// Hooks specifies the functions called on session lifecycle events.
+	Hooks Hooks
+
+	// Absolute specifies the maximum lifetime of a session from creation,
+	// regardless of activity. Zero disables the limit.
+	Absolute time.Duration
+}
+
+// Option applies an optional setting to the Options.
+type Option func(*Options)
+
+// NewOptions returns the default Options with the given options applied.
+func NewOptions(opts ...Option) Options {
+	options := Options{Codec: GobCodec}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithCodec sets the codec used to encode session values.
+// Values encoded with previously used codecs remain readable, provided the codec
+// is registered, and are re-encoded with the new codec when next set.
+func WithCodec(codec Codec) Option {
+	return func(o *Options) {
+		if codec != nil {
+			o.Codec = codec
+		}
+	}
+}
+
+// WithHooks sets the functions called on session lifecycle events.
+func WithHooks(hooks Hooks) Option {
+	return func(o *Options) {
+		o.Hooks = hooks
+	}
+}
+
+// WithAbsolute sets the maximum lifetime of a session from creation; zero
+// disables the limit. The lifetime is never extended by activity or Extend.
+func WithAbsolute(absolute time.Duration) Option {
+	return func(o *Options) {
+		o.Absolute = absolute
+	}
+}
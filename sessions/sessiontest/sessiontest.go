@@ -0,0 +1,468 @@
+// Package sessiontest provides a conformance test suite for sessions.Store
+// implementations that keep sessions on the server.
+package sessiontest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/sessions"
+)
+
+// Value is the type of the values the suite stores in sessions.
+type Value struct {
+	Data int
+}
+
+// Key and Expected specify the key and value the suite stores in sessions, which
+// HookRecorder expects the session of each event to hold.
+const Key = "sessiontest"
+
+var Expected = Value{42}
+
+// newRequest returns a recorder and request carrying cookie if it is not nil.
+func newRequest(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return w, r
+}
+
+// NewStore returns a new store with the given idle timeout and options applied.
+// The suite stops each store when the test using it completes.
+type NewStore func(t *testing.T, idle time.Duration, opts ...sessions.Option) sessions.Store
+
+// Run tests that the stores returned by newStore behave as specified by
+// sessions.Store, and sessions.UserIndexer if it is implemented.
+func Run(t *testing.T, newStore NewStore) {
+	t.Helper()
+
+	open := func(t *testing.T, idle time.Duration, opts ...sessions.Option) sessions.Store {
+		t.Helper()
+
+		store := newStore(t, idle, opts...)
+		t.Cleanup(store.Stop)
+		return store
+	}
+
+	t.Run("set get delete", func(t *testing.T) {
+		store := open(t, 90*time.Millisecond)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if len(w.Result().Cookies()) != 1 {
+			t.Fatal("failed to set cookie")
+		}
+		cookie := w.Result().Cookies()[0]
+
+		var out Value
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+		if !reflect.DeepEqual(Expected, out) {
+			t.Fatalf("expected %#v got %#v", Expected, out)
+		}
+
+		session = store.Session(newRequest(cookie))
+		session.Delete(Key)
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("extended and expired", func(t *testing.T) {
+		store := open(t, 90*time.Millisecond)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		session.Extend(180 * time.Millisecond)
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		cookie := w.Result().Cookies()[0]
+
+		time.Sleep(110 * time.Millisecond)
+
+		var out Value
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+
+		time.Sleep(90 * time.Millisecond)
+
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		store := open(t, time.Minute)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		var out Value
+		if err := session.Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		if len(w.Result().Cookies()) != 0 {
+			t.Fatal("expected no cookie for an empty session")
+		}
+	})
+
+	t.Run("renew", func(t *testing.T) {
+		store := open(t, 100*time.Millisecond)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		cookie := w.Result().Cookies()[0]
+
+		w, r = newRequest(cookie)
+		if err := store.Session(w, r).Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Fatal("expected a recently saved session not to be renewed")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		w, r = newRequest(cookie)
+		if err := store.Session(w, r).Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if len(w.Result().Cookies()) != 1 {
+			t.Fatal("expected the session to be renewed")
+		}
+	})
+
+	t.Run("typed keys", func(t *testing.T) {
+		store := open(t, time.Minute)
+
+		typedKey := sessions.NewKey[Value](Key)
+
+		session := store.Session(newRequest(nil))
+
+		if err := sessions.SetValue(session, typedKey, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+
+		out, err := sessions.Value(session, typedKey)
+		if err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+
+		if !reflect.DeepEqual(Expected, out) {
+			t.Fatalf("expected %#v got %#v", Expected, out)
+		}
+
+		if _, err := sessions.Value(session, sessions.NewKey[Value]("missing")); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("regenerate and destroy", func(t *testing.T) {
+		store := open(t, time.Minute)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		old := w.Result().Cookies()[0]
+
+		w, r = newRequest(old)
+		if err := store.Session(w, r).Regenerate(); err != nil {
+			t.Fatalf("failed to regenerate session: %v", err)
+		}
+		if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].Value == old.Value {
+			t.Fatal("failed to set regenerated cookie")
+		}
+		cookie := w.Result().Cookies()[0]
+
+		var out Value
+		if err := store.Session(newRequest(old)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected old session to be removed, got %v", err)
+		}
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != nil {
+			t.Fatalf("failed to get data: %v", err)
+		}
+
+		w, r = newRequest(cookie)
+		if err := store.Session(w, r).Destroy(); err != nil {
+			t.Fatalf("failed to destroy session: %v", err)
+		}
+		if len(w.Result().Cookies()) != 1 || w.Result().Cookies()[0].MaxAge >= 0 {
+			t.Fatal("failed to revoke cookie")
+		}
+
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected session to be removed, got %v", err)
+		}
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		store := open(t, 200*time.Millisecond, sessions.WithAbsolute(400*time.Millisecond))
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		created := session.CreatedAt()
+		lastSeen := session.LastSeen()
+		cookie := w.Result().Cookies()[0]
+
+		// the idle timeout is renewed by activity.
+		for range 3 {
+			time.Sleep(120 * time.Millisecond)
+
+			w, r = newRequest(cookie)
+			session := store.Session(w, r)
+
+			var out Value
+			if err := session.Get(Key, &out); err != nil {
+				t.Fatalf("failed to get data: %v", err)
+			}
+			if err := session.Save(); err != nil {
+				t.Fatalf("failed to save session: %v", err)
+			}
+
+			if !session.CreatedAt().Equal(created) {
+				t.Fatal("expected created datetime to be unchanged")
+			}
+			if !session.LastSeen().After(lastSeen) {
+				t.Fatal("expected last seen datetime to be renewed")
+			}
+
+			lastSeen = session.LastSeen()
+			cookie = w.Result().Cookies()[0]
+		}
+
+		// the absolute lifetime is not.
+		time.Sleep(80 * time.Millisecond)
+
+		var out Value
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		store := open(t, time.Minute)
+
+		w, r := newRequest(nil)
+		session := store.Session(w, r)
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		cookie := w.Result().Cookies()[0]
+
+		// overlapping changes to different keys are merged.
+		first := store.Session(newRequest(cookie))
+		second := store.Session(newRequest(cookie))
+
+		if err := first.Set("first", Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		second.Delete(Key)
+
+		if err := first.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if err := second.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		session = store.Session(newRequest(cookie))
+
+		var out Value
+		if err := session.Get("first", &out); err != nil {
+			t.Fatalf("expected first request's change to be kept, got %v", err)
+		}
+		if err := session.Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected second request's change to be kept, got %v", err)
+		}
+
+		// a session destroyed by another request stays destroyed.
+		stale := store.Session(newRequest(cookie))
+
+		if err := store.Session(newRequest(cookie)).Destroy(); err != nil {
+			t.Fatalf("failed to destroy session: %v", err)
+		}
+
+		if err := stale.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := stale.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		if err := store.Session(newRequest(cookie)).Get(Key, &out); err != sessions.ErrValueNotFound {
+			t.Fatalf("expected destroyed session to stay removed, got %v", err)
+		}
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		var recorder HookRecorder
+		store := open(t, time.Minute, sessions.WithHooks(recorder.Hooks()))
+
+		session := store.Session(newRequest(nil))
+
+		if err := session.Set(Key, Expected); err != nil {
+			t.Fatalf("failed to set data: %v", err)
+		}
+		if err := session.Save(); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if err := session.Regenerate(); err != nil {
+			t.Fatalf("failed to regenerate session: %v", err)
+		}
+		if err := session.Destroy(); err != nil {
+			t.Fatalf("failed to destroy session: %v", err)
+		}
+
+		events := []string{"create", "regenerate", "destroy"}
+		if got := recorder.Events(); !reflect.DeepEqual(events, got) {
+			t.Fatalf("expected %v got %v", events, got)
+		}
+	})
+
+	t.Run("user index", func(t *testing.T) {
+		store := open(t, time.Minute)
+		indexer, ok := store.(sessions.UserIndexer)
+		if !ok {
+			t.Skip("store does not implement sessions.UserIndexer")
+		}
+
+		login := func(userID string) *http.Cookie {
+			w, r := newRequest(nil)
+			r.Header.Set("User-Agent", "test-agent")
+			session := store.Session(w, r)
+
+			if err := sessions.BindUser(session, userID); err != nil {
+				t.Fatalf("failed to bind user: %v", err)
+			}
+			if err := session.Save(); err != nil {
+				t.Fatalf("failed to save session: %v", err)
+			}
+
+			return w.Result().Cookies()[0]
+		}
+
+		user1 := []*http.Cookie{login("user-1"), login("user-1")}
+		user2 := login("user-2")
+		login("user-10")
+
+		infos, err := indexer.SessionsForUser(t.Context(), "user-1")
+		if err != nil {
+			t.Fatalf("failed to list sessions: %v", err)
+		}
+		if len(infos) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(infos))
+		}
+		for _, info := range infos {
+			if info.UserID != "user-1" || info.IP != "192.0.2.1" || info.UserAgent != "test-agent" {
+				t.Fatalf("unexpected session info %#v", info)
+			}
+		}
+
+		if err := indexer.RevokeUser(t.Context(), "user-1"); err != nil {
+			t.Fatalf("failed to revoke sessions: %v", err)
+		}
+
+		for _, cookie := range user1 {
+			if id := store.Session(newRequest(cookie)).(sessions.UserBinder).UserID(); id != "" {
+				t.Fatalf("expected session to be revoked, got user %q", id)
+			}
+		}
+
+		if id := store.Session(newRequest(user2)).(sessions.UserBinder).UserID(); id != "user-2" {
+			t.Fatalf("expected user-2 session to remain, got user %q", id)
+		}
+	})
+}
+
+// HookRecorder records the session lifecycle events of a store, noting any
+// event whose session does not hold the data saved by the suite.
+type HookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// Hooks returns the hooks recording each event.
+func (h *HookRecorder) Hooks() sessions.Hooks {
+	return sessions.Hooks{
+		OnCreate:     func(id string, data sessions.Snapshot) { h.record("create", data) },
+		OnExpire:     func(id string, data sessions.Snapshot) { h.record("expire", data) },
+		OnDestroy:    func(id string, data sessions.Snapshot) { h.record("destroy", data) },
+		OnRegenerate: func(oldID, newID string, data sessions.Snapshot) { h.record("regenerate", data) },
+	}
+}
+
+// Events returns the recorded events in order.
+func (h *HookRecorder) Events() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return slices.Clone(h.events)
+}
+
+func (h *HookRecorder) record(event string, data sessions.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var out Value
+	if err := data.Get(Key, &out); err != nil || out != Expected {
+		event += " without data"
+	}
+	h.events = append(h.events, event)
+}
@@ -0,0 +1,44 @@
+package sqlitestore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/kvstore"
+	"github.com/dimmerz92/sittella/sessions/kvstore/sqlitekv"
+)
+
+// Store defines an SQLite backed session store to retrieve or generate request scoped sessions.
+// It is a kvstore.Store backed by a sqlitekv.KV.
+type Store struct {
+	*kvstore.Store
+	kv *sqlitekv.KV
+}
+
+// New returns a new SQLite backed session Store, creating the kv table described
+// by sqlitekv.Schema if it does not exist.
+// Parameters:
+// - db specifies the database the sessions are stored in.
+// - interval specifies the frequency that expired sessions are cleared.
+// - idle specifies how long a session lives without activity; it is renewed each time the session is saved.
+// - cookie specifies the cookie options to be used.
+// - opts specify optional store settings such as the value codec, absolute lifetime and lifecycle hooks.
+func New(db *database.Database, interval, idle time.Duration, cookie sessions.CookieOpts, opts ...sessions.Option) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("sqlitestore.New: db required")
+	}
+
+	kv, err := sqlitekv.New(db, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{Store: kvstore.New(kv, "", idle, cookie, opts...), kv: kv}, nil
+}
+
+// Stop releases any goroutines and resources allocated by the Store, waiting for
+// any clearing of expired sessions in progress to finish. The database is not
+// closed.
+func (s *Store) Stop() { s.kv.Close() }
@@ -0,0 +1,180 @@
+package sqlitestore_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/sessiontest"
+	"github.com/dimmerz92/sittella/sessions/sqlitestore"
+)
+
+func newRequest(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return w, r
+}
+
+func newStore(t *testing.T, interval, idle time.Duration, opts sessions.CookieOpts, storeOpts ...sessions.Option) *sqlitestore.Store {
+	t.Helper()
+
+	db := sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3") + "?_pragma=busy_timeout(5000)")
+	t.Cleanup(func() { db.Close() })
+
+	store, err := sqlitestore.New(db, interval, idle, opts, storeOpts...)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	return store
+}
+
+var key = sessiontest.Key
+
+var expected = sessiontest.Expected
+
+func TestSQLiteStore(t *testing.T) {
+	sessiontest.Run(t, func(t *testing.T, idle time.Duration, opts ...sessions.Option) sessions.Store {
+		return newStore(t, time.Minute, idle, sessions.CookieOpts{Name: "test"}, opts...)
+	})
+}
+
+func TestSQLiteStoreShared(t *testing.T) {
+	db := sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3") + "?_pragma=busy_timeout(5000)")
+	defer db.Close()
+
+	store1, err := sqlitestore.New(db, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store1.Stop()
+
+	store2, err := sqlitestore.New(db, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store2.Stop()
+
+	w, r := newRequest(nil)
+	session := store1.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	w, r = newRequest(w.Result().Cookies()[0])
+	session = store2.Session(w, r)
+
+	var out sessiontest.Value
+	if err := session.Get(key, &out); err != nil {
+		t.Fatalf("failed to get data: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, out) {
+		t.Fatalf("expected %#v got %#v", expected, out)
+	}
+}
+
+func TestSQLiteStoreCodecMigration(t *testing.T) {
+	db := sqlitedb.New(filepath.Join(t.TempDir(), "db.sqlite3") + "?_pragma=busy_timeout(5000)")
+	defer db.Close()
+
+	gobStore, err := sqlitestore.New(db, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer gobStore.Stop()
+
+	jsonStore, err := sqlitestore.New(db, time.Minute, time.Minute, sessions.CookieOpts{Name: "test"}, sessions.WithCodec(sessions.JSONCodec))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer jsonStore.Stop()
+
+	w, r := newRequest(nil)
+	session := gobStore.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	cookie := w.Result().Cookies()[0]
+	w, r = newRequest(cookie)
+	session = jsonStore.Session(w, r)
+
+	var out sessiontest.Value
+	if err := session.Get(key, &out); err != nil || !reflect.DeepEqual(expected, out) {
+		t.Fatalf("failed to get gob data with json store: %v", err)
+	}
+
+	if err := session.Set("other", expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	session = gobStore.Session(newRequest(cookie))
+
+	var out2 sessiontest.Value
+	if err := session.Get("other", &out2); err != nil || !reflect.DeepEqual(expected, out2) {
+		t.Fatalf("failed to get json data with gob store: %v", err)
+	}
+}
+
+func TestSQLiteStoreHooks(t *testing.T) {
+	var recorder sessiontest.HookRecorder
+	store := newStore(t, 20*time.Millisecond, 100*time.Millisecond, sessions.CookieOpts{Name: "test"},
+		sessions.WithHooks(recorder.Hooks()))
+	defer store.Stop()
+
+	w, r := newRequest(nil)
+	session := store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if err := session.Regenerate(); err != nil {
+		t.Fatalf("failed to regenerate session: %v", err)
+	}
+	if err := session.Destroy(); err != nil {
+		t.Fatalf("failed to destroy session: %v", err)
+	}
+
+	w, r = newRequest(nil)
+	session = store.Session(w, r)
+
+	if err := session.Set(key, expected); err != nil {
+		t.Fatalf("failed to set data: %v", err)
+	}
+	if err := session.Save(); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	events := []string{"create", "regenerate", "destroy", "create", "expire"}
+	if got := recorder.Events(); !reflect.DeepEqual(events, got) {
+		t.Fatalf("expected %v got %v", events, got)
+	}
+}
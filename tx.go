@@ -0,0 +1,84 @@
+package sittella
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/dimmerz92/sittella/database"
+)
+
+// txKey maps to the request transaction opened by the transaction middleware.
+var txKey = NewKey[*database.Tx]("sittella.tx")
+
+// Transaction returns a middleware that runs each request in a database
+// transaction, exposed by c.Tx() and carried by the request context so that
+// WithTx calls made with it become savepoints.
+//
+// The transaction is committed when the handler returns nil with a 2xx or 3xx
+// status, and rolled back otherwise, including when the middleware is applied to
+// all routes with Use and the route handler's error is passed to the error
+// handler. The response is held until the transaction completes, so the session
+// is saved after the commit and handlers cannot stream the response. If the
+// commit fails, the held response is discarded and the error returned.
+func Transaction() core.MiddlewareFunc { return transaction(false) }
+
+// ReadOnlyTransaction returns a middleware like Transaction whose transaction
+// rejects writes.
+func ReadOnlyTransaction() core.MiddlewareFunc { return transaction(true) }
+
+func transaction(readOnly bool) core.MiddlewareFunc {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(c core.Context) error {
+			tx, err := c.DB().NewTx(c.Request().Context(), &sql.TxOptions{ReadOnly: readOnly})
+			if err != nil {
+				return fmt.Errorf("sittella: begin transaction: %w", err)
+			}
+
+			SetValue(c, txKey, tx)
+
+			ctx, ok := c.(*context)
+			if ok {
+				ctx.req = ctx.req.WithContext(database.ContextWithTx(ctx.req.Context(), tx))
+				// hold the response so the session is saved once the transaction
+				// completes, and stores using the same database are not blocked.
+				if ctx.res.hold() {
+					defer ctx.res.release()
+				}
+			}
+
+			// roll back if the handler panics.
+			completed := false
+			defer func() {
+				if !completed {
+					tx.Rollback()
+				}
+			}()
+
+			err = next(c)
+			completed = true
+
+			// errors from route handlers wrapped by Use have already been passed
+			// to the error handler, so they only roll back the transaction.
+			if err != nil || (ok && ctx.handlerErr != nil) || !successful(c.Response().Status()) {
+				if rbErr := tx.Rollback(); rbErr != nil {
+					c.Logger().Error("sittella: failed to roll back transaction", "error", rbErr)
+				}
+				return err
+			}
+
+			if err := tx.Commit(); err != nil {
+				c.Logger().Error("sittella: failed to commit transaction", "error", err)
+				if ok {
+					ctx.res.discard()
+				}
+				return fmt.Errorf("sittella: commit transaction: %w", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// successful returns true for 2xx and 3xx statuses.
+func successful(status int) bool { return status >= 200 && status < 400 }
@@ -0,0 +1,186 @@
+package sittella
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/sittella/core"
+	"github.com/dimmerz92/sittella/database"
+	"github.com/dimmerz92/sittella/database/sqlitedb"
+	"github.com/dimmerz92/sittella/sessions"
+	"github.com/dimmerz92/sittella/sessions/sqlitestore"
+)
+
+// countItems returns the number of rows in the items table.
+func countItems(t *testing.T, db *database.Database) int {
+	t.Helper()
+
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM items"); err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	return count
+}
+
+func TestTransaction(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+	a.OnHandlerError(func(c core.Context, err error) {
+		c.NoContent(http.StatusInternalServerError)
+	})
+
+	if _, err := a.db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	for name, test := range map[string]struct {
+		respond func(c core.Context) error
+		status  int
+		commit  bool
+	}{
+		"written": {
+			respond: func(c core.Context) error { return c.NoContent(http.StatusCreated) },
+			status:  http.StatusCreated,
+			commit:  true,
+		},
+		"silent": {
+			respond: func(c core.Context) error { return nil },
+			status:  http.StatusOK,
+			commit:  true,
+		},
+		"redirect": {
+			respond: func(c core.Context) error { return c.Redirect(http.StatusSeeOther, "/") },
+			status:  http.StatusSeeOther,
+			commit:  true,
+		},
+		"client-error": {
+			respond: func(c core.Context) error { return c.NoContent(http.StatusBadRequest) },
+			status:  http.StatusBadRequest,
+		},
+		"handler-error": {
+			respond: func(c core.Context) error { return errors.New("test error") },
+			status:  http.StatusInternalServerError,
+		},
+		"written-error": {
+			respond: func(c core.Context) error {
+				c.NoContent(http.StatusCreated)
+				return errors.New("test error")
+			},
+			status: http.StatusCreated,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := "/" + name
+			a.POST(path, func(c core.Context) error {
+				if _, err := c.Tx().Exec("INSERT INTO items DEFAULT VALUES"); err != nil {
+					return err
+				}
+				return test.respond(c)
+			}, Transaction())
+
+			before := countItems(t, a.db)
+
+			w := do(a, httptest.NewRequest("POST", path, nil))
+			if w.Code != test.status {
+				t.Fatalf("expected %d got %d", test.status, w.Code)
+			}
+
+			committed := countItems(t, a.db) == before+1
+			if committed != test.commit {
+				t.Fatalf("expected commit %v got %v", test.commit, committed)
+			}
+		})
+	}
+
+	t.Run("read only", func(t *testing.T) {
+		a.POST("/read-only", func(c core.Context) error {
+			_, err := c.Tx().Exec("INSERT INTO items DEFAULT VALUES")
+			return err
+		}, ReadOnlyTransaction())
+
+		before := countItems(t, a.db)
+
+		if w := do(a, httptest.NewRequest("POST", "/read-only", nil)); w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500 got %d", w.Code)
+		}
+		if countItems(t, a.db) != before {
+			t.Fatal("expected read only transaction to reject writes")
+		}
+	})
+}
+
+func TestTransactionAppWide(t *testing.T) {
+	a := newTestApp(t, Config{}, nil)
+	a.Use(Transaction())
+
+	// the error handler writes nothing, so the response is still successful.
+	a.OnHandlerError(func(c core.Context, err error) {})
+
+	if _, err := a.db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	a.POST("/", func(c core.Context) error {
+		if _, err := c.Tx().Exec("INSERT INTO items DEFAULT VALUES"); err != nil {
+			return err
+		}
+		return errors.New("test error")
+	})
+
+	if w := do(a, httptest.NewRequest("POST", "/", nil)); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", w.Code)
+	}
+	if countItems(t, a.db) != 0 {
+		t.Fatal("expected transaction to be rolled back")
+	}
+}
+
+func TestTransactionSessionStore(t *testing.T) {
+	db, err := sqlitedb.Open(t.Context(), sqlitedb.DefaultOptions(filepath.Join(t.TempDir(), "db.sqlite3")))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	store, err := sqlitestore.New(db, time.Minute, time.Hour, sessions.CookieOpts{Name: "session"})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Stop()
+
+	a := newTestApp(t, Config{DB: db, SessionStore: store}, nil)
+
+	a.POST("/", func(c core.Context) error {
+		if _, err := c.Tx().Exec("INSERT INTO items DEFAULT VALUES"); err != nil {
+			return err
+		}
+		if err := c.Session().Set("name", "sittella"); err != nil {
+			return err
+		}
+		return c.String(http.StatusCreated, "created")
+	}, Transaction())
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- do(a, httptest.NewRequest("POST", "/", nil)) }()
+
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out saving the session during the transaction")
+	}
+
+	if w.Code != http.StatusCreated || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("expected 201 with a session cookie, got %d %v", w.Code, w.Result().Cookies())
+	}
+	if countItems(t, db) != 1 {
+		t.Fatal("expected transaction to be committed")
+	}
+}